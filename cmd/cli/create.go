@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+	"github.com/mibrahimzia/bitnet-runner/internal/templates"
+)
+
+var createCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a model from a Modelfile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(modelfileFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		mf, err := models.ParseModelfile(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", modelfileFlag, err)
+			os.Exit(1)
+		}
+		if mf.Params.Template != "" {
+			if _, err := templates.Get(mf.Params.Template); err != nil {
+				fmt.Printf("Error: %s: %v\n", modelfileFlag, err)
+				os.Exit(1)
+			}
+		}
+
+		// FROM paths are relative to the Modelfile, unless they name an installed model
+		mgr := models.NewManager()
+		if _, err := mgr.Find(mf.From); err != nil && !filepath.IsAbs(mf.From) {
+			mf.From = filepath.Join(filepath.Dir(modelfileFlag), mf.From)
+		}
+
+		info, err := mgr.Create(args[0], mf)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created %s from %s\n", info.ID, info.Filename)
+	},
+}
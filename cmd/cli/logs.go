@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/internal/utils"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the engine log",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := utils.GetLogsDir()
+		if err != nil {
+			fmt.Printf("Error locating logs: %v\n", err)
+			os.Exit(1)
+		}
+		path := filepath.Join(dir, engine.LogFile)
+
+		offset, err := printTail(path, linesFlag)
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Error reading %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		if !followFlag {
+			if os.IsNotExist(err) {
+				fmt.Println("No engine logs yet.")
+			}
+			return
+		}
+
+		for {
+			time.Sleep(500 * time.Millisecond)
+			offset, err = followFile(path, offset)
+			if err != nil && !os.IsNotExist(err) {
+				fmt.Printf("Error reading %s: %v\n", path, err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// printTail prints the last n lines of the file (all of them when n <= 0)
+// and returns the offset reached
+func printTail(path string, n int) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if n > 0 && len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	return f.Seek(0, io.SeekCurrent)
+}
+
+// followFile prints whatever was appended since offset. A file smaller than
+// offset has been rotated, so it is read from the start.
+func followFile(path string, offset int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return offset, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return offset, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+	n, err := io.Copy(os.Stdout, f)
+	return offset + n, err
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/mibrahimzia/bitnet-runner/internal/embedder"
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
 	"github.com/mibrahimzia/bitnet-runner/internal/engine"
 	"github.com/mibrahimzia/bitnet-runner/internal/models"
 	"github.com/mibrahimzia/bitnet-runner/internal/server"
@@ -13,16 +18,54 @@ import (
 
 // Global flags
 var (
-	hostFlag string
-	portFlag string
+	hostFlag   string
+	portFlag   string
+	engineFlag string
+)
+
+// Serve flags
+var (
+	maxModelsFlag    int
+	memoryBudgetFlag int64
+	loadTimeoutFlag  time.Duration
+	parallelFlag     int
+	queueLimitFlag   int
+	queueOrderFlag   string
 )
 
 // Run flags
 var (
-	tempFlag   float64
-	promptFlag string
+	tempFlag     float64
+	promptFlag   string
+	templateFlag string
+	seedFlag     int
+	presetFlag   string
+)
+
+// Load-time engine flags of serve and run
+var (
+	threadsFlag   int
+	ctxSizeFlag   int
+	batchSizeFlag int
+	mlockFlag     bool
+	noMMapFlag    bool
+)
+
+// Show flags
+var jsonFlag bool
+
+// Logs flags
+var (
+	linesFlag  int
+	followFlag bool
 )
 
+// Create flags
+var modelfileFlag string
+
+// Pull flags
+var pullSHA256Flag string
+
 var rootCmd = &cobra.Command{
 	Use:   "bitnet",
 	Short: "BitNet Inference Runner",
@@ -33,6 +76,7 @@ func main() {
 	// Define global flags
 	rootCmd.PersistentFlags().StringVar(&hostFlag, "host", "localhost", "Server host")
 	rootCmd.PersistentFlags().StringVar(&portFlag, "port", "8080", "Server port")
+	rootCmd.PersistentFlags().StringVar(&engineFlag, "engine", "", "Path to an external llama-server binary (default: $BITNET_ENGINE, config file, then the embedded engine)")
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -45,24 +89,82 @@ func init() {
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(lsCmd)
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(createCmd)
+	rootCmd.AddCommand(pullCmd)
+
+	// Add flags to serve command
+	serveCmd.Flags().IntVar(&maxModelsFlag, "max-models", 1, "Number of models kept loaded at the same time")
+	serveCmd.Flags().Int64Var(&memoryBudgetFlag, "memory-budget", 0, "Memory budget for loaded models in MB (0 = unlimited)")
+	serveCmd.Flags().DurationVar(&loadTimeoutFlag, "load-timeout", engine.DefaultLoadTimeout, "Time allowed per GB of model weights for a model to load")
+	serveCmd.Flags().IntVar(&parallelFlag, "parallel", 1, "Requests generated at the same time per model")
+	serveCmd.Flags().IntVar(&queueLimitFlag, "queue-limit", 32, "Requests allowed to wait per model before new ones get 429 (0 = unlimited)")
+	serveCmd.Flags().StringVar(&queueOrderFlag, "queue-order", engine.QueueFIFO, "Order of waiting requests (fifo, priority)")
 
 	// Add flags to run command
 	runCmd.Flags().Float64Var(&tempFlag, "temp", 0.8, "Temperature")
 	runCmd.Flags().StringVarP(&promptFlag, "prompt", "p", "", "Prompt text")
+	runCmd.Flags().StringVar(&templateFlag, "template", "", "Chat template (llama3, chatml, bitnet, mistral, phi); defaults to the model's own")
+	runCmd.Flags().IntVar(&seedFlag, "seed", -1, "Random seed (-1 = random)")
+	runCmd.Flags().StringVar(&presetFlag, "preset", "", "Generation preset (precise, creative, code or one from the config file); defaults to the model's")
+
+	for _, cmd := range []*cobra.Command{serveCmd, runCmd} {
+		cmd.Flags().IntVar(&threadsFlag, "threads", 0, "CPU threads for the engine (0 = engine default)")
+		cmd.Flags().IntVar(&ctxSizeFlag, "ctx-size", 0, "Context size in tokens per request (0 = 2048)")
+		cmd.Flags().IntVar(&batchSizeFlag, "batch-size", 0, "Prompt tokens evaluated at once (0 = engine default)")
+		cmd.Flags().BoolVar(&mlockFlag, "mlock", false, "Keep model weights locked in RAM")
+		cmd.Flags().BoolVar(&noMMapFlag, "no-mmap", false, "Read model weights into memory instead of mapping the file")
+	}
+
+	// Add flags to show command
+	showCmd.Flags().BoolVar(&jsonFlag, "json", false, "Print machine-readable JSON")
+
+	// Add flags to logs command
+	logsCmd.Flags().IntVarP(&linesFlag, "lines", "n", 50, "Number of lines to show (0 = all)")
+	logsCmd.Flags().BoolVarP(&followFlag, "follow", "f", false, "Keep printing new lines as they are written")
+
+	// Add flags to create command
+	createCmd.Flags().StringVarP(&modelfileFlag, "file", "f", "Modelfile", "Path to the Modelfile")
+
+	// Add flags to pull command
+	pullCmd.Flags().StringVar(&pullSHA256Flag, "sha256", "", "Expected SHA-256 of the file (default: the one Hugging Face lists)")
 }
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start the API server",
 	Run: func(cmd *cobra.Command, args []string) {
+		if queueOrderFlag != engine.QueueFIFO && queueOrderFlag != engine.QueuePriority {
+			fmt.Printf("Unknown queue order %q (use fifo or priority)\n", queueOrderFlag)
+			os.Exit(1)
+		}
+
 		fmt.Printf("Initializing BitNet Engine...\n")
 
-		srv, err := server.NewServer(portFlag)
+		pool := engine.DefaultPoolConfig()
+		pool.MaxModels = maxModelsFlag
+		pool.MemoryBudget = memoryBudgetFlag << 20
+		pool.LoadTimeout = loadTimeoutFlag
+		pool.Parallel = parallelFlag
+		pool.QueueLimit = queueLimitFlag
+		pool.QueueOrder = queueOrderFlag
+		pool.Load = engine.LoadOptions{
+			Threads:     threadsFlag,
+			ContextSize: ctxSizeFlag,
+			BatchSize:   batchSizeFlag,
+			MLock:       mlockFlag,
+			NoMMap:      noMMapFlag,
+		}
+
+		srv, err := server.NewServer(server.Config{Port: portFlag, Pool: pool, Engine: engineFlag})
 		if err != nil {
 			fmt.Printf("Error initializing server: %v", err)
 			os.Exit(1)
 		}
 
+		bin := srv.EngineInfo()
+		fmt.Printf("Using %s engine %s (version %s)\n", bin.Source, bin.Path, bin.Version)
 		fmt.Printf("Server listening on http://%s:%s\n", hostFlag, portFlag)
 		if err := srv.Start(); err != nil {
 			fmt.Printf("Server crashed: %v", err)
@@ -88,11 +190,13 @@ var lsCmd = &cobra.Command{
 			return
 		}
 
-		fmt.Printf("%-25s %-15s %s\n", "MODEL ID", "SIZE", "PATH")
-		fmt.Println("------------------------------------------------------------")
+		fmt.Printf("%-40s %-10s %-8s %-8s %-8s %-10s %s\n", "MODEL ID", "ARCH", "PARAMS", "QUANT", "CTX", "SIZE", "PATH")
+		fmt.Println("--------------------------------------------------------------------------------------------------------")
 		for _, m := range list {
 			sizeMB := m.Size / 1024 / 1024
-			fmt.Printf("%-25s %d MB        %s\n", m.ID, sizeMB, m.FilePath)
+			fmt.Printf("%-40s %-10s %-8s %-8s %-8d %-10s %s\n",
+				m.ID, m.Architecture, models.FormatParameterCount(m.ParameterCount),
+				m.Quantization, m.ContextLength, fmt.Sprintf("%d MB", sizeMB), m.FilePath)
 		}
 	},
 }
@@ -105,66 +209,146 @@ var runCmd = &cobra.Command{
 		modelFile := args[0]
 
 		// 1. Initialize Engine Resources
-		binPath, err := embedder.ExtractEngine()
+		bin, err := engine.ResolveBinary(engineFlag)
 		if err != nil {
-			fmt.Printf("Failed to extract engine: %v\n", err)
+			fmt.Printf("Failed to prepare engine: %v\n", err)
 			os.Exit(1)
 		}
 
-		// 2. Resolve Model Path
-		mgr := models.NewManager()
-		list, _ := mgr.List()
-		var fullPath string
+		exec := engine.NewExecutor(bin.Path)
+		defer exec.Shutdown()
 
-		// Simple search
-		for _, m := range list {
-			if m.ID == modelFile || m.Filename == modelFile {
-				fullPath = m.FilePath
-				break
-			}
-		}
-
-		if fullPath == "" {
-			// Fallback: check if user provided a full path
-			if _, err := os.Stat(modelFile); err == nil {
-				fullPath = modelFile
-			} else {
+		// 2. Resolve Model Path (model ID, filename or a full path).
+		// Models on a remote backend need not exist locally.
+		mgr := models.NewManager()
+		model, err := mgr.Resolve(modelFile)
+		if err != nil {
+			if !exec.IsRemote(modelFile) {
 				fmt.Printf("Model '%s' not found.\n", modelFile)
 				os.Exit(1)
 			}
+			model = models.ModelInfo{ID: modelFile, FilePath: modelFile}
 		}
 
-		// 3. Prepare Config
-		cfg := engine.InferenceConfig{
-			ModelPath:   fullPath,
-			Prompt:      promptFlag,
-			Temperature: tempFlag,
-			TopP:        0.9,
-			TopK:        40,
-			MaxTokens:   512,
-			Threads:     4,
+		// 3. Prepare Config: flags over the model's defaults and the preset
+		file, err := config.Load()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
-
-		// If no prompt flag, read from stdin (simple interactive mode)
-		if promptFlag == "" {
-			fmt.Print("Enter prompt: ")
-			fmt.Scanln(&cfg.Prompt)
+		cfg, err := engine.ResolveConfig(file, model, presetFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Apply(runParams(cmd))
+		cfg.Prompt = promptFlag
+		if err := cfg.Validate(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
 
 		fmt.Printf("Loading %s...\n", modelFile)
 
 		// 4. Execute
-		exec := engine.NewExecutor(binPath)
-		stream, err := exec.StartInference(cfg)
-		if err != nil {
-			fmt.Printf("Error starting inference: %v\n", err)
-			os.Exit(1)
+		if promptFlag != "" {
+			if _, err := chatTurn(exec, cfg); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
 		}
 
-		fmt.Print("\nBitNet: ")
-		for token := range stream {
-			fmt.Print(token)
+		// No prompt flag: interactive chat that keeps the conversation history
+		fmt.Println("Type a message, or /bye to exit.")
+		input := bufio.NewScanner(os.Stdin)
+		for {
+			fmt.Print(">>> ")
+			if !input.Scan() {
+				break
+			}
+			line := strings.TrimSpace(input.Text())
+			if line == "" {
+				continue
+			}
+			if line == "/bye" {
+				break
+			}
+
+			cfg.Prompt = line
+			reply, err := chatTurn(exec, cfg)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				continue
+			}
+			cfg.Messages = append(cfg.Messages,
+				engine.Message{Role: "user", Content: line},
+				engine.Message{Role: "assistant", Content: reply},
+			)
 		}
-		fmt.Println("\n")
 	},
+}
+
+// runParams collects the run flags given on the command line; the others
+// are left to the model's defaults and the preset
+func runParams(cmd *cobra.Command) config.Params {
+	var p config.Params
+	flags := cmd.Flags()
+	if flags.Changed("temp") {
+		p.Temperature = &tempFlag
+	}
+	if flags.Changed("seed") {
+		p.Seed = &seedFlag
+	}
+	if flags.Changed("threads") {
+		p.Threads = &threadsFlag
+	}
+	if flags.Changed("ctx-size") {
+		p.ContextSize = &ctxSizeFlag
+	}
+	if flags.Changed("batch-size") {
+		p.BatchSize = &batchSizeFlag
+	}
+	if flags.Changed("mlock") {
+		p.MLock = &mlockFlag
+	}
+	if flags.Changed("no-mmap") {
+		p.NoMMap = &noMMapFlag
+	}
+	p.Template = templateFlag
+	return p
+}
+
+// chatTurn streams one reply to stdout and returns the full text. Ctrl+C
+// stops the reply instead of the program.
+func chatTurn(exec *engine.Executor, cfg engine.InferenceConfig) (string, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	stream, err := exec.StartInference(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	var reply strings.Builder
+	var failed error
+	fmt.Print("\nBitNet: ")
+	for ev := range stream {
+		switch ev.Type {
+		case engine.StreamToken:
+			fmt.Print(ev.Token)
+			reply.WriteString(ev.Token)
+		case engine.StreamError:
+			failed = ev.Err
+		case engine.StreamDone:
+			if ev.Completion.StopReason == engine.StopCancelled {
+				fmt.Print(" [stopped]")
+			}
+		}
+	}
+	fmt.Print("\n\n")
+	if failed != nil {
+		return "", fmt.Errorf("generation failed: %w", failed)
+	}
+	return reply.String(), nil
 }
\ No newline at end of file
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/engine/enginetest"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+	"github.com/mibrahimzia/bitnet-runner/internal/models/modeltest"
+)
+
+// envCLI makes the re-executed test binary run the CLI with its arguments
+const envCLI = "BITNET_TEST_CLI"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envCLI) != "" {
+		// Engines started by the CLI must act as the fake engine, not the CLI
+		os.Unsetenv(envCLI)
+		main()
+		os.Exit(0)
+	}
+	enginetest.RunIfEngine()
+	os.Exit(m.Run())
+}
+
+// bitnet runs the CLI in a child process and returns its output and exit code
+func bitnet(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), envCLI+"=1")
+	out, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return string(out), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), 0
+}
+
+func installModel(t *testing.T) string {
+	t.Helper()
+	dir := modeltest.Home(t)
+	return modeltest.WriteGGUF(t, filepath.Join(dir, "tiny.gguf"), map[string]any{
+		"general.architecture":    "llama",
+		"general.file_type":       uint32(7),
+		"general.parameter_count": uint64(2_400_000_000),
+		"llama.context_length":    uint32(4096),
+	})
+}
+
+func TestLs(t *testing.T) {
+	modeltest.Home(t)
+	if out, code := bitnet(t, "ls"); code != 0 || !strings.Contains(out, "No models found") {
+		t.Errorf("empty ls = %d %q", code, out)
+	}
+
+	installModel(t)
+	out, code := bitnet(t, "ls")
+	if code != 0 {
+		t.Fatalf("ls exited with %d: %s", code, out)
+	}
+	fields := strings.Fields(strings.Split(out, "\n")[2])
+	if len(fields) < 5 || fields[0] != "tiny.gguf" || fields[1] != "llama" || fields[2] != "2.4B" || fields[3] != "Q8_0" || fields[4] != "4096" {
+		t.Errorf("ls row = %q", fields)
+	}
+}
+
+func TestShow(t *testing.T) {
+	installModel(t)
+
+	out, code := bitnet(t, "show", "tiny.gguf", "--json")
+	if code != 0 {
+		t.Fatalf("show exited with %d: %s", code, out)
+	}
+	var details models.ModelDetails
+	if err := json.Unmarshal([]byte(out), &details); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if details.Architecture != "llama" || details.ContextLength != 4096 {
+		t.Errorf("details = %+v", details.ModelInfo)
+	}
+
+	if out, code := bitnet(t, "show", "missing.gguf"); code != 1 || !strings.Contains(out, "not found") {
+		t.Errorf("show missing = %d %q", code, out)
+	}
+}
+
+func TestRunPrompt(t *testing.T) {
+	installModel(t)
+	fake := enginetest.Process(t, enginetest.Script{Tokens: []string{"Hello", ",", " world"}})
+
+	out, code := bitnet(t, "--engine", fake, "run", "tiny.gguf", "-p", "hi")
+	if code != 0 || !strings.Contains(out, "BitNet: Hello, world\n") {
+		t.Errorf("run = %d %q", code, out)
+	}
+
+	// The engine's output ends up in the log `bitnet logs` reads
+	out, code = bitnet(t, "logs", "-n", "0")
+	if code != 0 || !strings.Contains(out, "[tiny.gguf] fake engine listening on 127.0.0.1:") {
+		t.Errorf("logs = %d %q", code, out)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	installModel(t)
+
+	if out, code := bitnet(t, "run", "missing.gguf", "-p", "hi"); code != 1 || !strings.Contains(out, "Model 'missing.gguf' not found") {
+		t.Errorf("run missing = %d %q", code, out)
+	}
+
+	if out, code := bitnet(t, "--engine", "/bin/true", "run", "tiny.gguf", "-p", "hi"); code != 1 || !strings.Contains(out, "Failed to prepare engine") {
+		t.Errorf("run with a bad engine = %d %q", code, out)
+	}
+
+	fake := enginetest.Process(t, enginetest.Script{Tokens: []string{"a"}})
+	if out, code := bitnet(t, "--engine", fake, "run", "tiny.gguf", "--preset", "nope", "-p", "hi"); code != 1 || !strings.Contains(out, `unknown preset: "nope"`) {
+		t.Errorf("run with an unknown preset = %d %q", code, out)
+	}
+	if out, code := bitnet(t, "--engine", fake, "run", "tiny.gguf", "--temp", "3", "-p", "hi"); code != 1 || !strings.Contains(out, "temperature must be between 0 and 2") {
+		t.Errorf("run with a bad temperature = %d %q", code, out)
+	}
+
+	fake = enginetest.Process(t, enginetest.Script{LoadError: "failed to load model"})
+	if out, code := bitnet(t, "--engine", fake, "run", "tiny.gguf", "-p", "hi"); code != 1 || !strings.Contains(out, "failed to load model") {
+		t.Errorf("run with a failing load = %d %q", code, out)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	installModel(t)
+	modelfile := filepath.Join(t.TempDir(), "Modelfile")
+	os.WriteFile(modelfile, []byte("FROM tiny.gguf\nSYSTEM Be terse.\nPARAMETER temperature 0.2\n"), 0644)
+
+	if out, code := bitnet(t, "create", "terse", "-f", modelfile); code != 0 || !strings.Contains(out, "Created terse from tiny.gguf") {
+		t.Fatalf("create = %d %q", code, out)
+	}
+	if out, _ := bitnet(t, "ls"); !strings.Contains(out, "\nterse ") {
+		t.Errorf("ls does not list the new model: %q", out)
+	}
+	if out, _ := bitnet(t, "show", "terse"); !strings.Contains(out, `SYSTEM """Be terse."""`) || !strings.Contains(out, "PARAMETER temperature 0.2") {
+		t.Errorf("show = %q", out)
+	}
+
+	fake := enginetest.Process(t, enginetest.Script{Tokens: []string{"ok"}})
+	if out, code := bitnet(t, "--engine", fake, "run", "terse", "-p", "hi"); code != 0 || !strings.Contains(out, "BitNet: ok") {
+		t.Errorf("run terse = %d %q", code, out)
+	}
+
+	os.WriteFile(modelfile, []byte("FROM missing.gguf\n"), 0644)
+	if out, code := bitnet(t, "create", "broken", "-f", modelfile); code != 1 || !strings.Contains(out, "base model") {
+		t.Errorf("create from a missing model = %d %q", code, out)
+	}
+	os.WriteFile(modelfile, []byte("FROM tiny.gguf\nTEMPLATE jinja\n"), 0644)
+	if out, code := bitnet(t, "create", "broken", "-f", modelfile); code != 1 || !strings.Contains(out, "unknown chat template") {
+		t.Errorf("create with an unknown template = %d %q", code, out)
+	}
+	os.WriteFile(modelfile, []byte("FROM tiny.gguf\n"), 0644)
+	if out, code := bitnet(t, "create", "../escape", "-f", modelfile); code != 1 || !strings.Contains(out, "invalid model name") {
+		t.Errorf("create with a bad name = %d %q", code, out)
+	}
+}
+
+func TestPull(t *testing.T) {
+	dir := modeltest.Home(t)
+	data, err := os.ReadFile(modeltest.WriteGGUF(t, filepath.Join(t.TempDir(), "remote.gguf"), map[string]any{
+		"general.architecture": "llama",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+
+	// A stand-in for the Hugging Face Hub with one repository
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/models/org/bitnet/tree/main":
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"type": "file", "path": "bitnet-i2_s.gguf", "lfs": map[string]any{"oid": hex.EncodeToString(sum[:]), "size": len(data)}},
+				{"type": "file", "path": "bitnet-f16.gguf", "size": 1},
+			})
+		case "/org/bitnet/resolve/main/bitnet-i2_s.gguf":
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer hub.Close()
+	t.Setenv(models.EnvHFEndpoint, hub.URL)
+
+	out, code := bitnet(t, "pull", "org/bitnet:i2_s")
+	if code != 0 || !strings.Contains(out, "100%") || !strings.Contains(out, "Pulled bitnet-i2_s.gguf (sha256 verified)") {
+		t.Fatalf("pull = %d %q", code, out)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "bitnet-i2_s.gguf")); !bytes.Equal(got, data) {
+		t.Errorf("pulled file has %d bytes, want %d", len(got), len(data))
+	}
+	if out, _ := bitnet(t, "ls"); !strings.Contains(out, "\nbitnet-i2_s.gguf ") {
+		t.Errorf("ls does not list the pulled model: %q", out)
+	}
+
+	if out, code := bitnet(t, "pull", "org/bitnet:q8_0"); code != 1 || !strings.Contains(out, "no q8_0 file") {
+		t.Errorf("pull of a missing quantization = %d %q", code, out)
+	}
+	if out, code := bitnet(t, "pull", "hf://org/bitnet:bitnet-i2_s.gguf", "--sha256", strings.Repeat("0", 64)); code != 1 || !strings.Contains(out, "checksum mismatch") {
+		t.Errorf("pull with a wrong checksum = %d %q", code, out)
+	}
+}
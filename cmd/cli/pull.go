@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+)
+
+var pullCmd = &cobra.Command{
+	Use:   "pull [model]",
+	Short: "Download a model from Hugging Face or a URL",
+	Long: `Download a GGUF model into the models directory. The model is one of
+
+  hf://org/repo[:file]    a file of a Hugging Face repository
+  org/repo[:quant]        the file of a quantization, such as i2_s or Q4_K_M
+  https://host/model.gguf a direct link
+
+Without a file or quantization the repository's only GGUF file is used, or a
+common quantization (i2_s first). Set huggingface.endpoint in the config file
+or $HF_ENDPOINT to use a mirror. An interrupted download resumes when pulled
+again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := config.Load()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Ctrl+C stops the download and removes the partial file
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		remote, err := models.NewHFResolver(file).Resolve(ctx, args[0], "")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if pullSHA256Flag != "" {
+			remote.SHA256 = pullSHA256Flag
+		}
+
+		progress, err := models.NewManager().Download(ctx, remote.URL, remote.Filename, models.DownloadOptions{SHA256: remote.SHA256})
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		bar := &progressBar{out: os.Stderr, name: remote.Filename, total: remote.Size}
+		for status := range progress {
+			if status.Error != "" {
+				bar.finish()
+				fmt.Printf("Error: %s\n", status.Error)
+				os.Exit(1)
+			}
+			bar.update(status)
+		}
+		bar.finish()
+		if remote.SHA256 != "" {
+			fmt.Printf("Pulled %s (sha256 verified)\n", remote.Filename)
+		} else {
+			fmt.Printf("Pulled %s\n", remote.Filename)
+		}
+	},
+}
+
+// progressBar draws download progress on one terminal line
+type progressBar struct {
+	out   io.Writer
+	name  string
+	total int64 // size known before the download; the server's wins
+
+	drawn    bool
+	lastDraw time.Time
+}
+
+const progressBarWidth = 30
+
+func (b *progressBar) update(status models.DownloadStatus) {
+	if status.TotalBytes > 0 {
+		b.total = status.TotalBytes
+	}
+	// Redrawing for every chunk would flood the terminal
+	if !status.IsCompleted && time.Since(b.lastDraw) < 100*time.Millisecond {
+		return
+	}
+	b.lastDraw = time.Now()
+	b.drawn = true
+
+	line := "pulling " + b.name
+	if b.total > 0 {
+		fraction := min(float64(status.Downloaded)/float64(b.total), 1)
+		filled := int(fraction * progressBarWidth)
+		line += fmt.Sprintf(" %3.0f%% [%s%s] %s / %s", fraction*100,
+			strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+			formatBytes(status.Downloaded), formatBytes(b.total))
+	} else {
+		line += " " + formatBytes(status.Downloaded)
+	}
+	if status.Retry > 0 {
+		line += fmt.Sprintf(" (retry %d)", status.Retry)
+	}
+	// Pad over the rest of a longer previous line
+	fmt.Fprintf(b.out, "\r%-80s", line)
+}
+
+// finish ends the progress line so later output starts on a new one
+func (b *progressBar) finish() {
+	if b.drawn {
+		fmt.Fprintln(b.out)
+		b.drawn = false
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show [model]",
+	Short: "Show model metadata and tensors",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		mgr := models.NewManager()
+		details, err := mgr.Show(args[0])
+		if err != nil {
+			fmt.Printf("Error reading model: %v\n", err)
+			os.Exit(1)
+		}
+
+		if jsonFlag {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(details); err != nil {
+				fmt.Printf("Error encoding JSON: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		printDetails(details)
+	},
+}
+
+func printDetails(d *models.ModelDetails) {
+	fmt.Println("Model")
+	fmt.Printf("  %-20s %s\n", "id", d.ID)
+	fmt.Printf("  %-20s %s\n", "path", d.FilePath)
+	fmt.Printf("  %-20s %d MB\n", "size", d.Size/1024/1024)
+	fmt.Printf("  %-20s %d\n", "gguf version", d.GGUFVersion)
+	fmt.Printf("  %-20s %s\n", "architecture", d.Architecture)
+	fmt.Printf("  %-20s %s (%d)\n", "parameters", models.FormatParameterCount(d.ParameterCount), d.ParameterCount)
+	fmt.Printf("  %-20s %s\n", "quantization", d.Quantization)
+	fmt.Printf("  %-20s %d\n", "context length", d.ContextLength)
+	fmt.Printf("  %-20s %d\n", "embedding length", d.Layout.EmbeddingLength)
+	fmt.Printf("  %-20s %d\n", "blocks", d.Layout.BlockCount)
+	fmt.Printf("  %-20s %d / %d\n", "heads (q / kv)", d.Layout.HeadCount, d.Layout.HeadCountKV)
+
+	types := make([]string, 0, len(d.TensorTypes))
+	for t, n := range d.TensorTypes {
+		types = append(types, fmt.Sprintf("%s x%d", t, n))
+	}
+	sort.Strings(types)
+	fmt.Printf("  %-20s %s\n", "tensor types", strings.Join(types, ", "))
+
+	fmt.Println("\nRope")
+	fmt.Printf("  %-20s %d\n", "dimension count", d.Rope.DimensionCount)
+	fmt.Printf("  %-20s %g\n", "freq base", d.Rope.FreqBase)
+	if d.Rope.ScalingType != "" {
+		fmt.Printf("  %-20s %s (factor %g)\n", "scaling", d.Rope.ScalingType, d.Rope.ScalingFactor)
+	}
+
+	fmt.Println("\nTokenizer")
+	fmt.Printf("  %-20s %s\n", "model", d.Tokenizer.Model)
+	fmt.Printf("  %-20s %d\n", "vocab size", d.Tokenizer.VocabSize)
+	fmt.Printf("  %-20s %d / %d\n", "bos / eos token", d.Tokenizer.BOSToken, d.Tokenizer.EOSToken)
+
+	if d.Modelfile != nil {
+		fmt.Println("\nModelfile")
+		for _, line := range strings.Split(strings.TrimSpace(d.Modelfile.String()), "\n") {
+			fmt.Println("  " + line)
+		}
+	}
+
+	if d.ChatTemplate != "" {
+		fmt.Println("\nChat template")
+		for _, line := range strings.Split(d.ChatTemplate, "\n") {
+			fmt.Println("  " + line)
+		}
+	}
+
+	fmt.Printf("\nTensors (%d)\n", len(d.Tensors))
+	fmt.Printf("  %-40s %-22s %-8s %s\n", "NAME", "SHAPE", "TYPE", "OFFSET")
+	for _, t := range d.Tensors {
+		dims := make([]string, len(t.Shape))
+		for i, n := range t.Shape {
+			dims[i] = fmt.Sprint(n)
+		}
+		fmt.Printf("  %-40s %-22s %-8s %d\n", t.Name, "["+strings.Join(dims, ", ")+"]", t.Type, t.Offset)
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/utils"
+)
+
+// FileName is the name of the config file inside the app data directory
+const FileName = "config.json"
+
+// Config holds the user settings shared by the CLI, the API server and the desktop app
+type Config struct {
+	Engine      EngineConfig           `json:"engine"`
+	Models      map[string]ModelConfig `json:"models,omitempty"`  // keyed by model ID (file name)
+	Presets     map[string]Params      `json:"presets,omitempty"` // add to or replace the built-in presets
+	HuggingFace HuggingFaceConfig      `json:"huggingface"`
+}
+
+// EngineConfig selects the llama-server binary that runs models
+type EngineConfig struct {
+	Path string `json:"path,omitempty"` // external engine binary; empty uses the embedded one
+}
+
+// HuggingFaceConfig sets where Hugging Face model references are looked up
+type HuggingFaceConfig struct {
+	Endpoint string `json:"endpoint,omitempty"` // Hub or mirror URL; $HF_ENDPOINT overrides it, empty uses https://huggingface.co
+}
+
+// ModelConfig holds per-model settings
+type ModelConfig struct {
+	Backend     string `json:"backend,omitempty"`      // llama-server (default), llama-cli or remote
+	Binary      string `json:"binary,omitempty"`       // llama-cli executable; defaults to the one next to the engine
+	URL         string `json:"url,omitempty"`          // remote bitnet-runner, or a running llama-server to use instead of starting one
+	RemoteModel string `json:"remote_model,omitempty"` // model ID on the remote; defaults to the local ID
+
+	// Generation settings for the model, applied over the preset
+	Preset   string `json:"preset,omitempty"` // used when a request names no preset
+	Defaults Params `json:"defaults"`
+}
+
+// Params holds generation settings for a preset or a model's defaults.
+// Nil and empty fields leave the setting to the layer below.
+type Params struct {
+	Temperature      *float64        `json:"temperature,omitempty"`
+	TopP             *float64        `json:"top_p,omitempty"`
+	TopK             *int            `json:"top_k,omitempty"`
+	MinP             *float64        `json:"min_p,omitempty"`
+	TypicalP         *float64        `json:"typical_p,omitempty"`
+	RepeatPenalty    *float64        `json:"repeat_penalty,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	Mirostat         *int            `json:"mirostat,omitempty"`
+	MirostatTau      *float64        `json:"mirostat_tau,omitempty"`
+	MirostatEta      *float64        `json:"mirostat_eta,omitempty"`
+	LogitBias        map[int]float64 `json:"logit_bias,omitempty"`
+	MaxTokens        *int            `json:"max_tokens,omitempty"`
+	Seed             *int            `json:"seed,omitempty"`
+	Stop             []string        `json:"stop,omitempty"`
+	SystemPrompt     string          `json:"system_prompt,omitempty"`
+	Template         string          `json:"template,omitempty"`
+
+	// Applied when the model is loaded
+	Threads     *int  `json:"threads,omitempty"`
+	ContextSize *int  `json:"ctx_size,omitempty"`
+	BatchSize   *int  `json:"batch_size,omitempty"`
+	MLock       *bool `json:"mlock,omitempty"`
+	NoMMap      *bool `json:"no_mmap,omitempty"`
+}
+
+// Path returns the location of the config file
+func Path() (string, error) {
+	appDir, err := utils.GetAppDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, FileName), nil
+}
+
+// Load reads the config file. A missing file yields the defaults.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads the config from path. A missing file yields the defaults.
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,16 @@
+//go:build darwin
+
+package embedder
+
+import (
+	"embed"
+
+	"github.com/mibrahimzia/bitnet-runner/native/bitnet-engine/darwin"
+)
+
+// engineBinary is the name of the server executable inside the engine files
+const engineBinary = "bitnet-server"
+
+func engineFS() (embed.FS, error) {
+	return darwin.GetFS(), nil
+}
@@ -0,0 +1,16 @@
+//go:build linux
+
+package embedder
+
+import (
+	"embed"
+
+	"github.com/mibrahimzia/bitnet-runner/native/bitnet-engine/linux"
+)
+
+// engineBinary is the name of the server executable inside the engine files
+const engineBinary = "bitnet-server"
+
+func engineFS() (embed.FS, error) {
+	return linux.GetFS(), nil
+}
@@ -0,0 +1,16 @@
+//go:build !windows && !linux && !darwin
+
+package embedder
+
+import (
+	"embed"
+	"fmt"
+	"runtime"
+)
+
+// engineBinary is the name of the server executable inside the engine files
+const engineBinary = "bitnet-server"
+
+func engineFS() (embed.FS, error) {
+	return embed.FS{}, fmt.Errorf("no embedded engine for %s/%s", runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package embedder
+
+import (
+	"embed"
+
+	"github.com/mibrahimzia/bitnet-runner/native/bitnet-engine/windows"
+)
+
+// engineBinary is the name of the server executable inside the engine files
+const engineBinary = "bitnet.exe"
+
+func engineFS() (embed.FS, error) {
+	return windows.GetFS(), nil
+}
@@ -6,65 +6,232 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mibrahimzia/bitnet-runner/internal/utils"
-	"github.com/mibrahimzia/bitnet-runner/native/bitnet-engine/windows"
 )
 
-// ExtractEngine unpacks the embedded engine files to the local runtime directory
+// stagingSuffix marks a version directory that is still being extracted
+const stagingSuffix = ".tmp-"
+
+// trashSuffix marks an old version directory moved aside to be deleted
+const trashSuffix = ".old-"
+
+// usersDir, inside a version directory, holds an empty file per process
+// using that version, named after the process ID
+const usersDir = "users"
+
+var (
+	// versionDirPattern matches the directories named after a manifest version
+	versionDirPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+	// leftoverPattern matches staging and trash directories of a version
+	leftoverPattern = regexp.MustCompile(`^[0-9a-f]{16}(` + regexp.QuoteMeta(stagingSuffix) + `|` + regexp.QuoteMeta(trashSuffix) + `)`)
+)
+
+// ExtractEngine unpacks the embedded engine files to the local runtime directory.
+// Files go into a directory named after the content hash, so an unchanged
+// engine is only verified, never rewritten, and a running child never has
+// its binary replaced underneath it.
 func ExtractEngine() (string, error) {
 	// 1. Get target directory (e.g., ~/.bitnet_runner/bin/windows)
-	targetDir, err := utils.GetRuntimeDir()
+	runtimeDir, err := utils.GetRuntimeDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get runtime dir: %w", err)
 	}
 
 	// 2. Create directory if not exists
-	if err := utils.EnsureDir(targetDir); err != nil {
+	if err := utils.EnsureDir(runtimeDir); err != nil {
 		return "", fmt.Errorf("failed to create runtime dir: %w", err)
 	}
 
-	// 3. Get the embedded filesystem
-	// Note: Since we are Windows-only for now, we call the windows package directly
-	assets := windows.GetFS()
+	// 3. Get the embedded filesystem for this OS and hash its contents
+	assets, err := engineFS()
+	if err != nil {
+		return "", err
+	}
+	manifest, err := buildManifest(assets)
+	if err != nil {
+		return "", fmt.Errorf("failed to read embedded engine: %w", err)
+	}
 
-	// 4. Walk through embedded files and write them to disk
-	err = fs.WalkDir(assets, ".", func(path string, d fs.DirEntry, err error) error {
+	// 4. Extract the version if needed, or repair and verify an existing one
+	versionDir := filepath.Join(runtimeDir, manifest.Version)
+	if _, err := os.Stat(versionDir); os.IsNotExist(err) {
+		err = extractVersion(assets, manifest, runtimeDir, versionDir)
 		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
+			return "", fmt.Errorf("extraction failed: %w", err)
 		}
+	} else if err != nil {
+		return "", err
+	}
+	// Marking the version before verifying it means files removed by
+	// another process's cleanup in between are written again
+	if err := markInUse(versionDir); err != nil {
+		return "", fmt.Errorf("failed to mark engine in use: %w", err)
+	}
+	if err := repair(assets, manifest, versionDir); err != nil {
+		return "", err
+	}
 
-		// Read file from memory
-		data, err := assets.Open(path)
-		if err != nil {
+	// 5. Drop engines extracted by other versions of the app that no
+	// running process uses
+	removeOldVersions(runtimeDir, manifest.Version)
+
+	// Return the full path to the executable
+	return filepath.Join(versionDir, engineBinary), nil
+}
+
+// extractVersion writes all files into a staging directory, verifies them
+// and renames the directory into place in one step
+func extractVersion(assets fs.FS, manifest *Manifest, runtimeDir, versionDir string) error {
+	staging, err := os.MkdirTemp(runtimeDir, manifest.Version+stagingSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging) // no-op after a successful rename
+
+	for name := range manifest.Files {
+		if err := writeAsset(assets, name, filepath.Join(staging, filepath.FromSlash(name))); err != nil {
 			return err
 		}
-		defer data.Close()
+	}
+	if bad, err := manifest.verify(staging); err != nil {
+		return err
+	} else if len(bad) > 0 {
+		return fmt.Errorf("extracted files do not match the embedded engine: %s", strings.Join(bad, ", "))
+	}
+	if err := manifest.write(staging); err != nil {
+		return err
+	}
 
-		// Create file on disk
-		destPath := filepath.Join(targetDir, path)
-		out, err := os.Create(destPath)
-		if err != nil {
-			return fmt.Errorf("failed to create file %s: %w", destPath, err)
+	if err := os.Rename(staging, versionDir); err != nil {
+		// Another process may have extracted the same version meanwhile
+		if _, statErr := os.Stat(versionDir); statErr == nil {
+			return nil
 		}
-		defer out.Close()
+		return err
+	}
+	return nil
+}
 
-		// Copy data
-		_, err = io.Copy(out, data)
-		if err != nil {
-			return err
+// repair verifies an extracted version and rewrites only the files that are
+// missing or modified
+func repair(assets fs.FS, manifest *Manifest, versionDir string) error {
+	bad, err := manifest.verify(versionDir)
+	if err != nil {
+		return fmt.Errorf("failed to verify engine: %w", err)
+	}
+	if len(bad) == 0 {
+		return nil
+	}
+
+	for _, name := range bad {
+		if err := writeAsset(assets, name, filepath.Join(versionDir, filepath.FromSlash(name))); err != nil {
+			return fmt.Errorf("failed to repair engine file %s: %w", name, err)
 		}
+	}
+	if bad, err = manifest.verify(versionDir); err != nil {
+		return fmt.Errorf("failed to verify engine: %w", err)
+	} else if len(bad) > 0 {
+		return fmt.Errorf("engine files are corrupt: %s", strings.Join(bad, ", "))
+	}
+	return manifest.write(versionDir)
+}
 
-		return nil
-	})
+// writeAsset copies one embedded file to dest through a temporary file, so
+// readers never see a partially written file
+func writeAsset(assets fs.FS, name, dest string) error {
+	data, err := assets.Open(name)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
 
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+stagingSuffix)
 	if err != nil {
-		return "", fmt.Errorf("extraction failed: %w", err)
+		return fmt.Errorf("failed to create file %s: %w", dest, err)
 	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
 
-	// Return the full path to the executable
-	return filepath.Join(targetDir, "bitnet.exe"), nil
-}
\ No newline at end of file
+	if _, err := io.Copy(tmp, data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// Executable bits are needed on Linux and macOS; Windows ignores them
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// markInUse records that this process runs the engine in versionDir
+func markInUse(versionDir string) error {
+	dir := filepath.Join(versionDir, usersDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, strconv.Itoa(os.Getpid())), nil, 0644)
+}
+
+// inUse reports whether a running process has marked versionDir. Marks
+// left by processes that have exited are removed.
+func inUse(versionDir string) bool {
+	dir := filepath.Join(versionDir, usersDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	used := false
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err == nil && pid > 0 && processAlive(pid) {
+			used = true
+			continue
+		}
+		os.Remove(filepath.Join(dir, entry.Name()))
+	}
+	return used
+}
+
+// removeOldVersions deletes the other extracted versions no running process
+// uses, and staging or trash directories abandoned for over an hour.
+// Anything not named like a version directory is left alone.
+func removeOldVersions(runtimeDir, current string) {
+	entries, err := os.ReadDir(runtimeDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		dir := filepath.Join(runtimeDir, name)
+		switch {
+		case name == current || !entry.IsDir():
+		case versionDirPattern.MatchString(name):
+			if inUse(dir) {
+				continue
+			}
+			// Moving the directory aside fails as a whole while one of its
+			// files is open (on Windows), instead of deleting it halfway
+			trash := dir + trashSuffix + strconv.Itoa(os.Getpid())
+			if err := os.Rename(dir, trash); err != nil {
+				continue
+			}
+			_ = os.RemoveAll(trash)
+		case leftoverPattern.MatchString(name):
+			// May belong to another process extracting or deleting right now
+			if info, err := entry.Info(); err != nil || time.Since(info.ModTime()) < time.Hour {
+				continue
+			}
+			_ = os.RemoveAll(dir)
+		}
+	}
+}
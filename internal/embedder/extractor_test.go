@@ -0,0 +1,163 @@
+package embedder
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// testAssets stands in for the embedded engine files
+func testAssets(t *testing.T) (fstest.MapFS, *Manifest) {
+	t.Helper()
+	assets := fstest.MapFS{
+		engineBinary:     {Data: []byte("#!/bin/sh\necho engine\n")},
+		"lib/libggml.so": {Data: []byte("library")},
+	}
+	manifest, err := buildManifest(assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return assets, manifest
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExtractVersion(t *testing.T) {
+	runtimeDir := t.TempDir()
+	assets, manifest := testAssets(t)
+	versionDir := filepath.Join(runtimeDir, manifest.Version)
+
+	if err := extractVersion(assets, manifest, runtimeDir, versionDir); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(versionDir, "lib", "libggml.so")); got != "library" {
+		t.Errorf("lib/libggml.so = %q", got)
+	}
+	info, err := os.Stat(filepath.Join(versionDir, engineBinary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0100 == 0 {
+		t.Errorf("engine binary is not executable: %v", info.Mode())
+	}
+	if _, err := os.Stat(filepath.Join(versionDir, ManifestFile)); err != nil {
+		t.Errorf("manifest not written: %v", err)
+	}
+
+	// Another process got there first: its directory is kept as it is
+	if err := extractVersion(assets, manifest, runtimeDir, versionDir); err != nil {
+		t.Errorf("extracting an existing version: %v", err)
+	}
+	entries, _ := os.ReadDir(runtimeDir)
+	if len(entries) != 1 {
+		t.Errorf("runtime dir has %d entries, want only the version dir", len(entries))
+	}
+}
+
+func TestRepair(t *testing.T) {
+	runtimeDir := t.TempDir()
+	assets, manifest := testAssets(t)
+	versionDir := filepath.Join(runtimeDir, manifest.Version)
+	if err := extractVersion(assets, manifest, runtimeDir, versionDir); err != nil {
+		t.Fatal(err)
+	}
+
+	binary := filepath.Join(versionDir, engineBinary)
+	if err := os.WriteFile(binary, []byte("tampered"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(versionDir, "lib")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repair(assets, manifest, versionDir); err != nil {
+		t.Fatal(err)
+	}
+	if bad, err := manifest.verify(versionDir); err != nil || len(bad) > 0 {
+		t.Errorf("after repair: bad files %v, %v", bad, err)
+	}
+	if got := readFile(t, binary); got != string(assets[engineBinary].Data) {
+		t.Errorf("engine binary = %q", got)
+	}
+}
+
+// exitedPID returns the ID of a process that has finished
+func exitedPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestRemoveOldVersions(t *testing.T) {
+	runtimeDir := t.TempDir()
+	mkdir := func(name string, users ...int) string {
+		dir := filepath.Join(runtimeDir, name)
+		if err := os.MkdirAll(filepath.Join(dir, usersDir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, pid := range users {
+			if err := os.WriteFile(filepath.Join(dir, usersDir, strconv.Itoa(pid)), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	old := time.Now().Add(-2 * time.Hour)
+
+	current := mkdir("00000000000000aa")
+	unused := mkdir("00000000000000bb")
+	running := mkdir("00000000000000cc", os.Getpid())
+	exited := mkdir("00000000000000dd", exitedPID(t))
+	freshStaging := mkdir("00000000000000ee" + stagingSuffix + "123")
+	oldStaging := mkdir("00000000000000ee" + stagingSuffix + "456")
+	oldTrash := mkdir("00000000000000ff" + trashSuffix + "789")
+	other := mkdir("models")
+	for _, dir := range []string{oldStaging, oldTrash} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeOldVersions(runtimeDir, filepath.Base(current))
+
+	for _, dir := range []string{current, running, freshStaging, other} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("%s was removed: %v", filepath.Base(dir), err)
+		}
+	}
+	for _, dir := range []string{unused, exited, oldStaging, oldTrash} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s was kept", filepath.Base(dir))
+		}
+	}
+	if entries, _ := os.ReadDir(runtimeDir); len(entries) != 4 {
+		t.Errorf("runtime dir has %d entries, want 4", len(entries))
+	}
+}
+
+func TestMarkInUse(t *testing.T) {
+	dir := t.TempDir()
+	if inUse(dir) {
+		t.Error("unmarked version is in use")
+	}
+	if err := markInUse(dir); err != nil {
+		t.Fatal(err)
+	}
+	if !inUse(dir) {
+		t.Error("version marked by this process is not in use")
+	}
+}
@@ -0,0 +1,115 @@
+package embedder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestFile records the extracted files and their hashes in each runtime version dir
+const ManifestFile = "manifest.json"
+
+// Manifest lists the engine files with their SHA-256 hashes. Its version is
+// derived from the hashes, so a new engine build gets a new runtime directory.
+type Manifest struct {
+	Version string               `json:"version"`
+	Files   map[string]FileEntry `json:"files"` // keyed by slash-separated path
+}
+
+// FileEntry describes one engine file
+type FileEntry struct {
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// buildManifest hashes every file in the embedded engine filesystem
+func buildManifest(assets fs.FS) (*Manifest, error) {
+	m := &Manifest{Files: make(map[string]FileEntry)}
+	err := fs.WalkDir(assets, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		f, err := assets.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		entry, err := hashReader(f)
+		if err != nil {
+			return err
+		}
+		m.Files[path] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Files) == 0 {
+		return nil, fmt.Errorf("no engine files embedded")
+	}
+
+	names := make([]string, 0, len(m.Files))
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%s\n", name, m.Files[name].SHA256)
+	}
+	m.Version = hex.EncodeToString(h.Sum(nil))[:16]
+	return m, nil
+}
+
+// verify returns the files in dir that are missing or differ from the manifest
+func (m *Manifest) verify(dir string) ([]string, error) {
+	var bad []string
+	for name, want := range m.Files {
+		got, err := hashFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			bad = append(bad, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if got != want {
+			bad = append(bad, name)
+		}
+	}
+	sort.Strings(bad)
+	return bad, nil
+}
+
+func (m *Manifest) write(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFile), data, 0644)
+}
+
+func hashFile(path string) (FileEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	defer f.Close()
+	return hashReader(f)
+}
+
+func hashReader(r io.Reader) (FileEntry, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	return FileEntry{SHA256: hex.EncodeToString(h.Sum(nil)), Size: n}, nil
+}
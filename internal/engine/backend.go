@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+)
+
+// Backend types, selectable per model with "backend" in the config file
+const (
+	BackendLlamaServer = "llama-server" // llama-server child process over HTTP, or an existing one at url (default)
+	BackendLlamaCLI    = "llama-cli"    // one llama-cli process per generation
+	BackendRemote      = "remote"       // another bitnet-runner instance
+)
+
+// ErrUnsupported is returned for operations a backend cannot perform
+var ErrUnsupported = errors.New("not supported by this backend")
+
+// Backend runs one model. The executor calls Load once before anything
+// else and Unload when the model is evicted; Generate, Tokenize, Embed and
+// Health may be called concurrently in between.
+type Backend interface {
+	// Load prepares the model, giving up when ctx expires
+	Load(ctx context.Context) error
+	// Unload releases the model and stops any running generations
+	Unload() error
+	// Generate streams the completion of an already rendered prompt as
+	// token events followed by a done or error event. Once ctx is cancelled
+	// the channel may be closed without a final event.
+	Generate(ctx context.Context, req GenerateRequest) (<-chan StreamEvent, error)
+	Tokenize(ctx context.Context, text string) ([]int, error)
+	Embed(ctx context.Context, text string) ([]float32, error)
+	// Health returns nil when the model can serve requests
+	Health(ctx context.Context) error
+}
+
+// GenerateRequest is a completion request as passed to a Backend
+type GenerateRequest struct {
+	Prompt        string
+	Messages      []Message // set instead of Prompt for a backend that applies the model's chat template itself
+	MaxTokens     int
+	Temperature   float64
+	TopP          float64
+	TopK          int
+	RepeatPenalty float64
+	Stop          []string
+	Seed          int // -1 picks a random seed
+
+	// Zero values leave these to the engine
+	MinP             float64
+	TypicalP         float64
+	PresencePenalty  float64
+	FrequencyPenalty float64
+	Mirostat         int
+	MirostatTau      float64
+	MirostatEta      float64
+	LogitBias        map[int]float64
+}
+
+// processBackend is implemented by backends that keep a child process
+// running between requests. The executor restarts them when it dies.
+type processBackend interface {
+	Backend
+	// Exited is closed when the process exits
+	Exited() <-chan struct{}
+	// ExitReason reports the exit status and the last lines of output
+	ExitReason() (string, []string)
+}
+
+// newBackend creates the backend configured for a model, looked up by its
+// file name (or full path) in the config file's "models" section
+func (e *Executor) newBackend(modelPath string, load LoadOptions) Backend {
+	mc, ok := e.pool.Models[filepath.Base(modelPath)]
+	if !ok {
+		mc = e.pool.Models[modelPath]
+	}
+
+	switch mc.Backend {
+	case BackendLlamaCLI:
+		bin := mc.Binary
+		if bin == "" {
+			bin = filepath.Join(filepath.Dir(e.binPath), "llama-cli"+exeSuffix())
+		}
+		return newLlamaCLI(bin, modelPath, load, e.logs)
+	case BackendRemote:
+		return newRemote(mc.URL, mc.RemoteModel, modelPath)
+	default:
+		return newLlamaServer(e.binPath, modelPath, mc.URL, e.slots(), load, e.logs)
+	}
+}
+
+// backendName reports the configured backend type of a model
+func (e *Executor) backendName(modelPath string) string {
+	mc, ok := e.pool.Models[filepath.Base(modelPath)]
+	if !ok {
+		mc = e.pool.Models[modelPath]
+	}
+	if mc.Backend == "" {
+		return BackendLlamaServer
+	}
+	return mc.Backend
+}
+
+// IsRemote reports whether the model ID is served by a remote backend, in
+// which case it does not need to exist on disk
+func (e *Executor) IsRemote(id string) bool {
+	return e.backendName(id) == BackendRemote
+}
+
+func exeSuffix() string {
+	if runtime.GOOS == "windows" {
+		return ".exe"
+	}
+	return ""
+}
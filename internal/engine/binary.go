@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/embedder"
+)
+
+// EnvEngine names the environment variable that selects an external engine binary
+const EnvEngine = "BITNET_ENGINE"
+
+// Where the engine binary came from, in order of precedence
+const (
+	SourceFlag     = "flag"
+	SourceEnv      = "env"
+	SourceConfig   = "config"
+	SourceEmbedded = "embedded"
+)
+
+// probeTimeout bounds how long the engine may take to print its version
+const probeTimeout = 10 * time.Second
+
+// BinaryInfo describes the engine binary in use
+type BinaryInfo struct {
+	Path    string `json:"path"`
+	Source  string `json:"source"`  // flag, env, config or embedded
+	Version string `json:"version"` // as reported by --version; empty if unknown
+}
+
+// ResolveBinary picks the engine binary: the --engine flag value, then
+// $BITNET_ENGINE, then engine.path from the config file, and finally the
+// embedded engine. External binaries must answer --version or --help.
+func ResolveBinary(flagPath string) (BinaryInfo, error) {
+	info := BinaryInfo{Path: flagPath, Source: SourceFlag}
+	if info.Path == "" {
+		info = BinaryInfo{Path: os.Getenv(EnvEngine), Source: SourceEnv}
+	}
+	if info.Path == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return BinaryInfo{}, err
+		}
+		info = BinaryInfo{Path: cfg.Engine.Path, Source: SourceConfig}
+	}
+
+	if info.Path == "" {
+		path, err := embedder.ExtractEngine()
+		if err != nil {
+			return BinaryInfo{}, err
+		}
+		info = BinaryInfo{Path: path, Source: SourceEmbedded}
+		// The embedded engine is trusted; the version is informational
+		info.Version, _ = ProbeVersion(path)
+		return info, nil
+	}
+
+	abs, err := filepath.Abs(info.Path)
+	if err == nil {
+		info.Path = abs
+	}
+	stat, err := os.Stat(info.Path)
+	if err != nil {
+		return BinaryInfo{}, fmt.Errorf("engine from %s: %w", info.Source, err)
+	}
+	if stat.IsDir() {
+		return BinaryInfo{}, fmt.Errorf("engine from %s: %s is a directory", info.Source, info.Path)
+	}
+	if info.Version, err = ProbeVersion(info.Path); err != nil {
+		return BinaryInfo{}, fmt.Errorf("engine from %s: %w", info.Source, err)
+	}
+	return info, nil
+}
+
+// ProbeVersion runs the binary with --version and returns the reported
+// version. Builds without --version are accepted if their --help output
+// looks like llama-server, in which case the version is "unknown".
+func ProbeVersion(path string) (string, error) {
+	out, err := runProbe(path, "--version")
+	if err == nil {
+		for _, line := range strings.Split(out, "\n") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(line), "version:"); ok {
+				return strings.TrimSpace(v), nil
+			}
+		}
+	}
+
+	help, helpErr := runProbe(path, "--help")
+	if helpErr != nil && help == "" {
+		if err == nil {
+			err = helpErr
+		}
+		return "", fmt.Errorf("%s does not run: %w", path, err)
+	}
+	if !strings.Contains(help, "--port") {
+		return "", fmt.Errorf("%s does not look like llama-server (no --port option in --help)", path)
+	}
+	return "unknown", nil
+}
+
+func runProbe(path string, arg string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, arg)
+	configureProcess(cmd)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
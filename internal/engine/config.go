@@ -1,16 +1,62 @@
 package engine
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/templates"
+)
+
+// Message is a single turn of a conversation
+type Message = templates.Message
+
+// ErrInvalidConfig is returned by StartInference for parameters out of range
+var ErrInvalidConfig = errors.New("invalid inference parameters")
+
 // InferenceConfig holds parameters for text generation
 type InferenceConfig struct {
-	ModelPath     string  `json:"model_path"`
-	Prompt        string  `json:"prompt"`
-	SystemPrompt  string  `json:"system_prompt"`
-	Temperature   float64 `json:"temperature"`     // 0.0 to 2.0
-	TopP          float64 `json:"top_p"`           // 0.0 to 1.0
-	TopK          int     `json:"top_k"`           // 0 to 100
-	RepeatPenalty float64 `json:"repeat_penalty"`  // 1.0 to 2.0
-	MaxTokens     int     `json:"max_tokens"`      // -1 for infinite
-	Threads       int     `json:"threads"`         // number of CPU threads
+	ModelPath     string    `json:"model_path"`
+	Messages      []Message `json:"messages"`       // conversation so far, oldest first
+	Prompt        string    `json:"prompt"`         // appended as the final user turn
+	SystemPrompt  string    `json:"system_prompt"`  // used when Messages has no system turn
+	Temperature   float64   `json:"temperature"`    // 0.0 to 2.0
+	TopP          float64   `json:"top_p"`          // 0.0 to 1.0
+	TopK          int       `json:"top_k"`          // 0 or more; 0 disables
+	RepeatPenalty float64   `json:"repeat_penalty"` // 0 or more; 1.0 disables
+	MaxTokens     int       `json:"max_tokens"`     // -1 for infinite
+	Stop          []string  `json:"stop"`           // sequences that end generation
+	Seed          int       `json:"seed"`           // 0 or -1 for a random seed
+	Raw           bool      `json:"raw"`            // send Prompt verbatim, without the chat template
+	Template      string    `json:"template"`       // built-in chat template name; empty uses the model's own
+	RequestID     string    `json:"request_id"`     // identifies the generation for Cancel; empty picks a new ID
+	Priority      int       `json:"priority"`       // higher runs first when the pool orders its queue by priority
+
+	// Further sampling settings; zero values leave them to the engine
+	MinP             float64         `json:"min_p"`             // 0.0 to 1.0
+	TypicalP         float64         `json:"typical_p"`         // 0.0 to 1.0; 1.0 disables
+	PresencePenalty  float64         `json:"presence_penalty"`  // -2.0 to 2.0
+	FrequencyPenalty float64         `json:"frequency_penalty"` // -2.0 to 2.0
+	Mirostat         int             `json:"mirostat"`          // 0 off, 1 or 2 for Mirostat v1 or v2
+	MirostatTau      float64         `json:"mirostat_tau"`      // target entropy
+	MirostatEta      float64         `json:"mirostat_eta"`      // learning rate
+	LogitBias        map[int]float64 `json:"logit_bias"`        // token ID to bias, -100 to 100
+
+	// Settings applied when the request loads the model. A model that is
+	// idle but was loaded with other settings is reloaded; a busy one keeps
+	// its settings. Zero values, and nil switches, use the pool's defaults;
+	// a switch set to false turns off one the pool has on.
+	Threads     int   `json:"threads"`    // number of CPU threads
+	ContextSize int   `json:"ctx_size"`   // context per slot in tokens
+	BatchSize   int   `json:"batch_size"` // prompt tokens evaluated at once
+	MLock       *bool `json:"mlock"`      // keep the weights locked in RAM
+	NoMMap      *bool `json:"no_mmap"`    // read the weights into memory instead of mapping the file
+
+	// OnQueued is called with the request's place in the queue (starting
+	// at 1) whenever it changes while the request waits for a slot. It runs
+	// on the goroutine calling StartInference; if it is slow, positions in
+	// between are skipped.
+	OnQueued func(position int) `json:"-"`
 }
 
 func DefaultConfig() InferenceConfig {
@@ -20,6 +66,103 @@ func DefaultConfig() InferenceConfig {
 		TopK:          40,
 		RepeatPenalty: 1.1,
 		MaxTokens:     512,
-		Threads:       4,
+		Seed:          -1,
+	}
+}
+
+// Validate checks that every parameter is within the range the engine accepts
+func (c InferenceConfig) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: "+format, append([]any{ErrInvalidConfig}, args...)...)
+	}
+
+	switch {
+	case c.Temperature < 0 || c.Temperature > 2:
+		return invalid("temperature must be between 0 and 2, got %g", c.Temperature)
+	case c.TopP < 0 || c.TopP > 1:
+		return invalid("top_p must be between 0 and 1, got %g", c.TopP)
+	case c.TopK < 0:
+		return invalid("top_k must not be negative, got %d", c.TopK)
+	case c.RepeatPenalty < 0:
+		return invalid("repeat_penalty must not be negative, got %g", c.RepeatPenalty)
+	case c.MaxTokens < -1:
+		return invalid("max_tokens must be -1 (no limit) or more, got %d", c.MaxTokens)
+	case c.MinP < 0 || c.MinP > 1:
+		return invalid("min_p must be between 0 and 1, got %g", c.MinP)
+	case c.TypicalP < 0 || c.TypicalP > 1:
+		return invalid("typical_p must be between 0 and 1, got %g", c.TypicalP)
+	case c.PresencePenalty < -2 || c.PresencePenalty > 2:
+		return invalid("presence_penalty must be between -2 and 2, got %g", c.PresencePenalty)
+	case c.FrequencyPenalty < -2 || c.FrequencyPenalty > 2:
+		return invalid("frequency_penalty must be between -2 and 2, got %g", c.FrequencyPenalty)
+	case c.Mirostat < 0 || c.Mirostat > 2:
+		return invalid("mirostat must be 0, 1 or 2, got %d", c.Mirostat)
+	case c.MirostatTau < 0 || c.MirostatEta < 0:
+		return invalid("mirostat_tau and mirostat_eta must not be negative")
+	case c.Threads < 0 || c.ContextSize < 0 || c.BatchSize < 0:
+		return invalid("threads, ctx_size and batch_size must not be negative")
+	}
+	for token, bias := range c.LogitBias {
+		if token < 0 {
+			return invalid("logit_bias has a negative token ID %d", token)
+		}
+		if bias < -100 || bias > 100 {
+			return invalid("logit_bias for token %d must be between -100 and 100, got %g", token, bias)
+		}
+	}
+	return nil
+}
+
+// LoadOptions are engine settings fixed while a model is loaded. Zero values
+// leave the choice to the engine.
+type LoadOptions struct {
+	Threads     int  `json:"threads,omitempty"`
+	ContextSize int  `json:"ctx_size,omitempty"` // per slot; 0 uses 2048
+	BatchSize   int  `json:"batch_size,omitempty"`
+	MLock       bool `json:"mlock,omitempty"`
+	NoMMap      bool `json:"no_mmap,omitempty"`
+}
+
+// loadOptions returns the load-time settings the request asks for, with
+// the ones it leaves unset taken from defaults
+func (c InferenceConfig) loadOptions(defaults LoadOptions) LoadOptions {
+	o := defaults
+	if c.Threads != 0 {
+		o.Threads = c.Threads
+	}
+	if c.ContextSize != 0 {
+		o.ContextSize = c.ContextSize
+	}
+	if c.BatchSize != 0 {
+		o.BatchSize = c.BatchSize
+	}
+	set(&o.MLock, c.MLock)
+	set(&o.NoMMap, c.NoMMap)
+	return o
+}
+
+// contextSize is the context per slot the engine is started with
+func (o LoadOptions) contextSize() int {
+	if o.ContextSize > 0 {
+		return o.ContextSize
 	}
-}
\ No newline at end of file
+	return 2048
+}
+
+// args are the llama.cpp command line flags for the options
+func (o LoadOptions) args() []string {
+	var args []string
+	if o.Threads > 0 {
+		args = append(args, "-t", strconv.Itoa(o.Threads))
+	}
+	if o.BatchSize > 0 {
+		args = append(args, "-b", strconv.Itoa(o.BatchSize))
+	}
+	if o.MLock {
+		args = append(args, "--mlock")
+	}
+	if o.NoMMap {
+		args = append(args, "--no-mmap")
+	}
+	return args
+}
@@ -0,0 +1,50 @@
+package engine_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*engine.InferenceConfig)
+		valid  bool
+	}{
+		{"defaults", func(c *engine.InferenceConfig) {}, true},
+		{"full set", func(c *engine.InferenceConfig) {
+			c.MinP, c.TypicalP = 0.05, 0.9
+			c.PresencePenalty, c.FrequencyPenalty = -1, 2
+			c.Mirostat, c.MirostatTau, c.MirostatEta = 2, 5, 0.1
+			c.LogitBias = map[int]float64{15043: -100}
+			c.Threads, c.ContextSize, c.BatchSize = 8, 4096, 512
+		}, true},
+		{"temperature too high", func(c *engine.InferenceConfig) { c.Temperature = 2.5 }, false},
+		{"negative top_k", func(c *engine.InferenceConfig) { c.TopK = -1 }, false},
+		{"large top_k", func(c *engine.InferenceConfig) { c.TopK = 500 }, true},
+		{"repeat penalty below 1", func(c *engine.InferenceConfig) { c.RepeatPenalty = 0.9 }, true},
+		{"negative repeat penalty", func(c *engine.InferenceConfig) { c.RepeatPenalty = -0.1 }, false},
+		{"top_p above 1", func(c *engine.InferenceConfig) { c.TopP = 1.1 }, false},
+		{"min_p above 1", func(c *engine.InferenceConfig) { c.MinP = 2 }, false},
+		{"presence penalty below -2", func(c *engine.InferenceConfig) { c.PresencePenalty = -3 }, false},
+		{"unknown mirostat", func(c *engine.InferenceConfig) { c.Mirostat = 3 }, false},
+		{"negative context", func(c *engine.InferenceConfig) { c.ContextSize = -1 }, false},
+		{"bias out of range", func(c *engine.InferenceConfig) { c.LogitBias = map[int]float64{1: 101} }, false},
+		{"negative bias token", func(c *engine.InferenceConfig) { c.LogitBias = map[int]float64{-1: 1} }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := engine.DefaultConfig()
+			tt.change(&cfg)
+			err := cfg.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, engine.ErrInvalidConfig) {
+				t.Errorf("Validate() = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
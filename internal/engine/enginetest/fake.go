@@ -0,0 +1,243 @@
+// Package enginetest provides a fake llama-server for tests and offline
+// development. It speaks the subset of the llama-server HTTP API the engine
+// uses (/health, /completion with SSE streaming, /tokenize, /embedding) and
+// follows a Script instead of running a model.
+//
+// The fake runs either in-process (New) or as a child process (Process), in
+// which case the test binary itself plays the engine: call RunIfEngine at
+// the start of TestMain.
+package enginetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Script describes how the fake engine behaves
+type Script struct {
+	Tokens     []string      // streamed in order for every completion
+	TokenDelay time.Duration // pause before each token
+	LoadDelay  time.Duration // /health reports "loading" for this long after start
+
+	LoadError   string // /health fails with this message
+	ExitOnStart bool   // process mode: exit with an error instead of serving
+
+	CompletionStatus int    // /completion fails with this HTTP status
+	CompletionError  string // message sent with CompletionStatus
+
+	CrashAfter int // process mode: exit after streaming this many tokens (0 = never)
+	CutAfter   int // end the stream after this many tokens without the final chunk (0 = never)
+}
+
+// Request is a /completion request as received by the fake
+type Request struct {
+	Prompt           string       `json:"prompt"`
+	NPredict         int          `json:"n_predict"`
+	Temperature      float64      `json:"temperature"`
+	TopP             float64      `json:"top_p"`
+	TopK             int          `json:"top_k"`
+	MinP             float64      `json:"min_p"`
+	TypicalP         float64      `json:"typical_p"`
+	RepeatPenalty    float64      `json:"repeat_penalty"`
+	PresencePenalty  float64      `json:"presence_penalty"`
+	FrequencyPenalty float64      `json:"frequency_penalty"`
+	Mirostat         int          `json:"mirostat"`
+	MirostatTau      float64      `json:"mirostat_tau"`
+	MirostatEta      float64      `json:"mirostat_eta"`
+	LogitBias        [][2]float64 `json:"logit_bias"`
+	Stop             []string     `json:"stop"`
+	Seed             int          `json:"seed"`
+	Stream           bool         `json:"stream"`
+}
+
+// Server is an in-process fake llama-server
+type Server struct {
+	*httptest.Server
+	fake *fake
+}
+
+// New starts an in-process fake engine that is closed when the test ends.
+// Point a model at it with the llama-server backend's url setting.
+func New(t testing.TB, script Script) *Server {
+	f := newFake(script, nil)
+	s := &Server{Server: httptest.NewServer(f), fake: f}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the /completion requests received so far
+func (s *Server) Requests() []Request {
+	return s.fake.requests()
+}
+
+// fake implements the llama-server endpoints
+type fake struct {
+	script  Script
+	started time.Time
+	crash   func() // called when CrashAfter is reached; nil in-process
+
+	mu       sync.Mutex
+	received []Request
+}
+
+func newFake(script Script, crash func()) *fake {
+	return &fake{script: script, started: time.Now(), crash: crash}
+}
+
+func (f *fake) requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.received...)
+}
+
+func (f *fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/health":
+		f.health(w)
+	case "/completion":
+		f.completion(w, r)
+	case "/tokenize":
+		f.tokenize(w, r)
+	case "/embedding":
+		f.embedding(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fake) health(w http.ResponseWriter) {
+	switch {
+	case time.Since(f.started) < f.script.LoadDelay:
+		writeError(w, http.StatusServiceUnavailable, "Loading model")
+	case f.script.LoadError != "":
+		writeError(w, http.StatusInternalServerError, f.script.LoadError)
+	default:
+		writeJSON(w, map[string]string{"status": "ok"})
+	}
+}
+
+func (f *fake) completion(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f.mu.Lock()
+	f.received = append(f.received, req)
+	f.mu.Unlock()
+
+	if f.script.CompletionStatus != 0 {
+		writeError(w, f.script.CompletionStatus, f.script.CompletionError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(chunk map[string]any) {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	// The final chunk reports why generation stopped, like llama-server
+	final := map[string]any{"content": "", "stop": true, "stopped_eos": true}
+	start := time.Now()
+	predicted := 0
+	for i, token := range f.script.Tokens {
+		if stop := stopsAt(token, req.Stop); stop != "" {
+			final = map[string]any{"content": "", "stop": true, "stopped_word": true, "stopping_word": stop}
+			break
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(f.script.TokenDelay):
+		}
+		send(map[string]any{"content": token, "stop": false})
+		predicted++
+		if f.crash != nil && f.script.CrashAfter > 0 && i+1 >= f.script.CrashAfter {
+			f.crash()
+		}
+		if f.script.CutAfter > 0 && i+1 >= f.script.CutAfter {
+			return
+		}
+		if req.NPredict > 0 && i+1 >= req.NPredict {
+			final = map[string]any{"content": "", "stop": true, "stopped_limit": true}
+			break
+		}
+	}
+
+	elapsed := float64(time.Since(start).Microseconds())/1000 + 0.001
+	final["tokens_evaluated"] = len(strings.Fields(req.Prompt))
+	final["tokens_predicted"] = predicted
+	final["timings"] = map[string]any{
+		"prompt_n":             len(strings.Fields(req.Prompt)),
+		"prompt_ms":            0.5,
+		"prompt_per_second":    float64(len(strings.Fields(req.Prompt))) / 0.0005,
+		"predicted_n":          predicted,
+		"predicted_ms":         elapsed,
+		"predicted_per_second": float64(predicted) * 1000 / elapsed,
+	}
+	send(final)
+}
+
+// tokenize returns one token per whitespace-separated word, numbered from 1
+func (f *fake) tokenize(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	tokens := []int{}
+	for i := range strings.Fields(req.Content) {
+		tokens = append(tokens, i+1)
+	}
+	writeJSON(w, map[string][]int{"tokens": tokens})
+}
+
+// embedding returns a small vector derived from the text length
+func (f *fake) embedding(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	n := float32(len(req.Content))
+	writeJSON(w, map[string][]float32{"embedding": {n, n / 2, 1}})
+}
+
+// stopsAt returns the stop sequence the token contains, if any
+func stopsAt(token string, stops []string) string {
+	for _, stop := range stops {
+		if stop != "" && strings.Contains(token, stop) {
+			return stop
+		}
+	}
+	return ""
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError uses the error shape of current llama-server versions
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"code": status, "message": message},
+	})
+}
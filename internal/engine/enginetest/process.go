@@ -0,0 +1,87 @@
+package enginetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Environment variables that turn a re-executed test binary into the fake engine
+const (
+	envEngine = "BITNET_FAKE_ENGINE"
+	envScript = "BITNET_FAKE_ENGINE_SCRIPT"
+)
+
+// Version is what the fake engine reports for --version
+const Version = "0 (fake)"
+
+// Process makes child processes started from the returned path (the test
+// binary itself) act as a fake llama-server following script. The test's
+// TestMain must call RunIfEngine.
+func Process(t testing.TB, script Script) string {
+	data, err := json.Marshal(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envEngine, "1")
+	t.Setenv(envScript, string(data))
+	return os.Args[0]
+}
+
+// RunIfEngine serves the fake engine and exits when the process was started
+// as one by Process. Otherwise it returns immediately.
+func RunIfEngine() {
+	if os.Getenv(envEngine) == "" {
+		return
+	}
+
+	var script Script
+	if err := json.Unmarshal([]byte(os.Getenv(envScript)), &script); err != nil {
+		fmt.Fprintf(os.Stderr, "fake engine: bad script: %v\n", err)
+		os.Exit(1)
+	}
+
+	host, port := "127.0.0.1", "8080"
+	args := os.Args[1:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--version":
+			fmt.Println("version: " + Version)
+			os.Exit(0)
+		case "--help":
+			fmt.Println("usage: llama-server [options]\n  --port PORT   port to listen on")
+			os.Exit(0)
+		case "--port":
+			i++
+			port = args[i]
+		case "--host":
+			i++
+			host = args[i]
+		}
+	}
+
+	if script.ExitOnStart {
+		fmt.Fprintln(os.Stderr, "error: failed to load model")
+		os.Exit(1)
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "couldn't bind HTTP server socket: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "fake engine args: %s\n", strings.Join(args, " "))
+	fmt.Fprintf(os.Stderr, "fake engine listening on %s\n", ln.Addr())
+
+	crash := func() {
+		fmt.Fprintln(os.Stderr, "fake engine: crashing as scripted")
+		os.Exit(2)
+	}
+	err = http.Serve(ln, newFake(script, crash))
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType names a step in a model's lifecycle
+type EventType string
+
+const (
+	EventLoading    EventType = "loading"
+	EventReady      EventType = "ready"
+	EventFailed     EventType = "failed" // loading gave up
+	EventCrashed    EventType = "crashed"
+	EventRestarting EventType = "restarting"
+	EventQueued     EventType = "queued" // a request moved in the queue for the model
+)
+
+// Event reports a change in the state of a loaded model or its queue
+type Event struct {
+	Type    EventType `json:"type"`
+	Model   string    `json:"model"` // path of the model file
+	Time    time.Time `json:"time"`
+	Reason  string    `json:"reason,omitempty"`  // why loading failed or the engine crashed
+	Attempt int       `json:"attempt,omitempty"` // restart attempt, starting at 1
+
+	Request  string `json:"request,omitempty"`  // ID of the queued request
+	Position int    `json:"position,omitempty"` // its place in the queue, starting at 1
+}
+
+// eventBuffer is how many items a slow subscriber may fall behind
+// before further ones are dropped for it
+const eventBuffer = 64
+
+// broadcaster fans values out to subscribers without ever blocking the engine
+type broadcaster[T any] struct {
+	mu   sync.Mutex
+	subs map[chan T]struct{}
+}
+
+func (b *broadcaster[T]) subscribe() (<-chan T, func()) {
+	ch := make(chan T, eventBuffer)
+
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[chan T]struct{})
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *broadcaster[T]) publish(v T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- v:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of lifecycle events and a function that
+// unsubscribes and closes it
+func (e *Executor) Subscribe() (<-chan Event, func()) {
+	return e.events.subscribe()
+}
+
+func (e *Executor) emit(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	e.events.publish(ev)
+}
@@ -1,139 +1,154 @@
 package engine
 
 import (
-	"bufio"
-	"bytes"
-	"context" // Added context
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
-	"os/exec"
-	"strings"
+	"path/filepath"
 	"sync"
-	"syscall" 
 	"time"
-)
-
-type ServerRequest struct {
-	Prompt        string  `json:"prompt"`
-	NPredict      int     `json:"n_predict"`
-	Temperature   float64 `json:"temperature"`
-	TopP          float64 `json:"top_p"`
-	TopK          int     `json:"top_k"`
-	RepeatPenalty float64 `json:"repeat_penalty"`
-	Stream        bool    `json:"stream"`
-}
 
-type ServerResponse struct {
-	Content string `json:"content"`
-	Stop    bool   `json:"stop"`
-}
+	"github.com/mibrahimzia/bitnet-runner/internal/templates"
+)
 
 type Executor struct {
-	binPath     string
-	cmd         *exec.Cmd
-	mu          sync.Mutex
-	running     bool
-	activeModel string
-	serverPort  string
-	
-	// Context for the active chat request
-	cancelRequest context.CancelFunc
+	binPath   string
+	mu        sync.Mutex
+	pool      PoolConfig
+	instances map[string]*instance           // loaded models by path
+	templates map[string]*templates.Template // chat template per model path
+	crashes   []Crash                        // recent engine crashes, oldest first
+	events    broadcaster[Event]
+	logs      *LogStore
+	requests  map[string]*inflight   // generations in flight by request ID
+	queues    map[string]*modelQueue // generation slots per model path
+	idle      chan struct{}          // closed when a loaded model may be evictable
 }
 
 func NewExecutor(binaryPath string) *Executor {
+	return NewPoolExecutor(binaryPath, DefaultPoolConfig())
+}
+
+// NewPoolExecutor creates an executor that keeps up to pool.MaxModels
+// models loaded, each served by the backend configured for it (by default
+// its own llama-server child process)
+func NewPoolExecutor(binaryPath string, pool PoolConfig) *Executor {
 	return &Executor{
-		binPath:    binaryPath,
-		serverPort: "8080",
+		binPath:   binaryPath,
+		pool:      pool,
+		instances: make(map[string]*instance),
+		templates: make(map[string]*templates.Template),
+		requests:  make(map[string]*inflight),
+		queues:    make(map[string]*modelQueue),
+		logs:      NewLogStore(pool.LogDir),
 	}
 }
 
+// Logs returns the captured output of the engine processes
+func (e *Executor) Logs() *LogStore {
+	return e.logs
+}
+
 // LoadModel starts the server without running inference
 func (e *Executor) LoadModel(modelPath string) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	if e.running && e.activeModel == modelPath {
-		return nil // Already loaded
+	inst, err := e.acquire(context.Background(), modelPath, nil)
+	if err != nil {
+		return err
 	}
-
-	return e.restartServerLocked(modelPath)
+	e.release(inst)
+	return nil
 }
 
-func (e *Executor) StartInference(config InferenceConfig) (<-chan string, error) {
-	e.mu.Lock()
-	// Auto-load if not ready
-	if !e.running || e.activeModel != config.ModelPath {
-		if err := e.restartServerLocked(config.ModelPath); err != nil {
-			e.mu.Unlock()
-			return nil, err
-		}
+// StartInference generates a reply and streams it token by token, ending
+// with a done event that says why generation stopped, or an error event. The
+// generation is registered under config.RequestID (a new ID when empty) until
+// the stream closes; cancelling ctx or calling Cancel with that ID stops it
+// without affecting other requests.
+func (e *Executor) StartInference(ctx context.Context, config InferenceConfig) (<-chan StreamEvent, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
-	
-	// Cancel any previous request just in case
-	if e.cancelRequest != nil {
-		e.cancelRequest()
+	// A remote model renders the conversation with its own chat template,
+	// unless the request names one
+	var messages []Message
+	var prompt string
+	var templateStop []string
+	var err error
+	if e.IsRemote(config.ModelPath) && !config.Raw && config.Template == "" {
+		messages = config.Conversation()
+	} else if prompt, templateStop, err = e.buildPrompt(config); err != nil {
+		return nil, err
 	}
-	
-	// Create new cancellable context for this specific request
-	ctx, cancel := context.WithCancel(context.Background())
-	e.cancelRequest = cancel
-	e.mu.Unlock()
 
-	// Use the simpler template from your working config
-	fullPrompt := fmt.Sprintf("System: %sUser: %s<|eot_id|>Assistant:", config.SystemPrompt, config.Prompt)
+	ctx, id, err := e.register(ctx, config)
+	if err != nil {
+		return nil, err
+	}
 
+	// Wait for a free slot on the model
+	if err := e.enqueue(ctx, config.ModelPath, config, id); err != nil {
+		e.unregister(id)
+		return nil, err
+	}
 
+	// Auto-load if not ready
+	load := config.loadOptions(e.pool.Load)
+	inst, err := e.acquire(ctx, config.ModelPath, &load)
+	if err != nil {
+		e.dequeue(config.ModelPath)
+		e.unregister(id)
+		return nil, err
+	}
 
-	
-	reqBody := ServerRequest{
-		Prompt:        fullPrompt,
-		NPredict:      config.MaxTokens,
+	req := GenerateRequest{
+		Prompt:        prompt,
+		Messages:      messages,
+		MaxTokens:     config.MaxTokens,
 		Temperature:   config.Temperature,
 		TopP:          config.TopP,
 		TopK:          config.TopK,
 		RepeatPenalty: config.RepeatPenalty,
-		Stream:        true,
+		Stop:          append(append([]string{}, config.Stop...), templateStop...),
+		Seed:          config.Seed,
+
+		MinP:             config.MinP,
+		TypicalP:         config.TypicalP,
+		PresencePenalty:  config.PresencePenalty,
+		FrequencyPenalty: config.FrequencyPenalty,
+		Mirostat:         config.Mirostat,
+		MirostatTau:      config.MirostatTau,
+		MirostatEta:      config.MirostatEta,
+		LogitBias:        config.LogitBias,
 	}
-
-	jsonData, _ := json.Marshal(reqBody)
-
-	url := fmt.Sprintf("http://127.0.0.1:%s/completion", e.serverPort)
-	
-	// Create request with Context
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+	if req.Seed == 0 {
+		req.Seed = -1 // -1 means "pick a random seed"
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	events, err := inst.backend.Generate(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("connection failed: %w", err)
+		e.release(inst)
+		e.dequeue(config.ModelPath)
+		e.unregister(id)
+		return nil, err
 	}
 
-	outputChan := make(chan string)
+	outputChan := make(chan StreamEvent)
 
 	go func() {
 		defer close(outputChan)
-		defer resp.Body.Close()
-
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.HasPrefix(line, "data: ") {
-				jsonStr := strings.TrimPrefix(line, "data: ")
-				if strings.Contains(jsonStr, "[DONE]") {
-					return
-				}
-				var data ServerResponse
-				if err := json.Unmarshal([]byte(jsonStr), &data); err == nil {
-					outputChan <- data.Content
-					if data.Stop {
-						return
-					}
-				}
+		defer e.unregister(id)
+
+		final := forward(ctx, events, outputChan)
+		e.release(inst)
+		e.dequeue(config.ModelPath)
+
+		// A cancelled caller may have stopped reading, so it only gets a
+		// moment to take the final event
+		select {
+		case outputChan <- final:
+		case <-ctx.Done():
+			select {
+			case outputChan <- final:
+			case <-time.After(finalEventWait):
 			}
 		}
 	}()
@@ -141,75 +156,70 @@ func (e *Executor) StartInference(config InferenceConfig) (<-chan string, error)
 	return outputChan, nil
 }
 
-// Stop cancels the CURRENT GENERATION but keeps the server running
+// Tokenize converts text into token IDs using the given model's tokenizer
+func (e *Executor) Tokenize(modelPath string, text string) ([]int, error) {
+	inst, err := e.acquire(context.Background(), modelPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer e.release(inst)
+
+	return inst.backend.Tokenize(context.Background(), text)
+}
+
+// Embed returns the embedding vector of text from the given model
+func (e *Executor) Embed(modelPath string, text string) ([]float32, error) {
+	inst, err := e.acquire(context.Background(), modelPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer e.release(inst)
+
+	return inst.backend.Embed(context.Background(), text)
+}
+
+// Health checks a loaded model without loading it
+func (e *Executor) Health(modelPath string) error {
+	e.mu.Lock()
+	inst, ok := e.instances[modelPath]
+	e.mu.Unlock()
+	if !ok || !inst.isReady() || inst.loadErr != nil {
+		return fmt.Errorf("%s is not loaded", filepath.Base(modelPath))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return inst.backend.Health(ctx)
+}
+
+// Stop cancels every generation in flight but keeps the servers running.
+// Use Cancel to stop a single request.
 func (e *Executor) Stop() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
-	
-	if e.cancelRequest != nil {
-		e.cancelRequest() // This cuts the HTTP connection
-		e.cancelRequest = nil
+
+	for _, r := range e.requests {
+		r.cancel() // This cuts the HTTP connection
 	}
 	return nil
 }
 
-// Shutdown actually kills the server process (used when closing app)
+// Shutdown actually kills the server processes (used when closing app)
 func (e *Executor) Shutdown() error {
 	e.mu.Lock()
-	defer e.mu.Unlock()
-	
-	if e.cancelRequest != nil {
-		e.cancelRequest()
+	for _, r := range e.requests {
+		r.cancel()
 	}
-	
-	if e.cmd != nil && e.cmd.Process != nil {
-		e.running = false
-		return e.cmd.Process.Kill()
-	}
-	return nil
-}
 
-func (e *Executor) restartServerLocked(modelPath string) error {
-	if e.cmd != nil && e.cmd.Process != nil {
-		_ = e.cmd.Process.Kill()
-		e.cmd.Wait()
-	}
-
-	args := []string{
-		"-m", modelPath,
-		"--port", e.serverPort,
-		"-c", "2048",
-		"--host", "127.0.0.1",
-	}
-
-	// fmt.Printf("DEBUG: Starting Server: %s %v\n", e.binPath, args) // Comment out debug log for production
-	cmd := exec.Command(e.binPath, args...)
-	
-	// --- THIS HIDES THE BLACK WINDOW ---
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow: true,
-		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
-	}
-	// -----------------------------------
-	
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start server: %w", err)
-	}
-    // ... rest of function stays the same
-
-	e.cmd = cmd
-	e.running = true
-	e.activeModel = modelPath
-
-	// Wait for server health
-	fmt.Println("DEBUG: Waiting for model to load...")
-	for i := 0; i < 30; i++ { 
-		time.Sleep(500 * time.Millisecond)
-		_, err := http.Get(fmt.Sprintf("http://127.0.0.1:%s/health", e.serverPort))
-		if err == nil {
-			fmt.Println("DEBUG: Model Loaded!")
-			return nil
-		}
+	var stale []*instance
+	for path, inst := range e.instances {
+		stale = append(stale, inst)
+		delete(e.instances, path)
+		e.dropQueueLocked(path)
 	}
+	e.notifyIdleLocked()
+	e.mu.Unlock()
+
+	stopAll(stale)
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,575 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine/enginetest"
+	"github.com/mibrahimzia/bitnet-runner/internal/models/modeltest"
+)
+
+func TestMain(m *testing.M) {
+	enginetest.RunIfEngine()
+	os.Exit(m.Run())
+}
+
+// attached returns an executor whose model is served by an in-process fake
+// with two slots
+func attached(t *testing.T, script enginetest.Script) (*engine.Executor, string, *enginetest.Server) {
+	t.Helper()
+	return attachedPool(t, script, engine.PoolConfig{MaxModels: 1, Parallel: 2})
+}
+
+// attachedPool is attached with the pool's queue settings
+func attachedPool(t *testing.T, script enginetest.Script, pool engine.PoolConfig) (*engine.Executor, string, *enginetest.Server) {
+	t.Helper()
+	fake := enginetest.New(t, script)
+	model := filepath.Join(t.TempDir(), "tiny.gguf")
+	pool.Models = map[string]config.ModelConfig{"tiny.gguf": {URL: fake.URL}}
+	e := engine.NewPoolExecutor("", pool)
+	t.Cleanup(func() { e.Shutdown() })
+	return e, model, fake
+}
+
+// spawned returns an executor that starts the fake engine as a child process
+func spawned(t *testing.T, script enginetest.Script, pool engine.PoolConfig) *engine.Executor {
+	t.Helper()
+	e := engine.NewPoolExecutor(enginetest.Process(t, script), pool)
+	t.Cleanup(func() { e.Shutdown() })
+	return e
+}
+
+// collect reads a stream to the end and returns the text and the final event
+func collect(t *testing.T, stream <-chan engine.StreamEvent) (string, engine.StreamEvent) {
+	t.Helper()
+	var b strings.Builder
+	var final engine.StreamEvent
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case ev, ok := <-stream:
+			if !ok {
+				if final.Type == "" {
+					t.Fatal("stream closed without a done or error event")
+				}
+				return b.String(), final
+			}
+			if final.Type != "" {
+				t.Fatalf("%s event after the %s event", ev.Type, final.Type)
+			}
+			if ev.Type == engine.StreamToken {
+				b.WriteString(ev.Token)
+			} else {
+				final = ev
+			}
+		case <-timeout:
+			t.Fatal("generation did not finish")
+		}
+	}
+}
+
+func TestStartInference(t *testing.T) {
+	e, model, fake := attached(t, enginetest.Script{Tokens: []string{"Hello", ",", " world", "!"}})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "hi"
+	cfg.Template = "chatml"
+	cfg.Stop = []string{"!"}
+	cfg.Seed = 0
+	cfg.MaxTokens = 16
+
+	tokens, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, final := collect(t, tokens)
+	if got != "Hello, world" {
+		t.Errorf("output = %q, want the tokens up to the stop word", got)
+	}
+	if c := final.Completion; c == nil || c.StopReason != engine.StopWord || c.StopWord != "!" || c.TokensPredicted != 3 || c.TokensEvaluated == 0 {
+		t.Errorf("final event = %+v", final)
+	}
+
+	reqs := fake.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("engine got %d requests, want 1", len(reqs))
+	}
+	req := reqs[0]
+	if !strings.Contains(req.Prompt, "<|im_start|>user\nhi") {
+		t.Errorf("prompt not rendered with the template: %q", req.Prompt)
+	}
+	if req.Stop[0] != "!" || len(req.Stop) < 2 {
+		t.Errorf("stop = %q, want the request's then the template's", req.Stop)
+	}
+	if req.Seed != -1 || req.NPredict != 16 || !req.Stream {
+		t.Errorf("seed %d, n_predict %d, stream %v", req.Seed, req.NPredict, req.Stream)
+	}
+}
+
+func TestStartInferenceMaxTokens(t *testing.T) {
+	e, model, _ := attached(t, enginetest.Script{Tokens: []string{"a", "b", "c", "d"}})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	cfg.Raw = true
+	cfg.MaxTokens = 2
+
+	tokens, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, final := collect(t, tokens)
+	if got != "ab" {
+		t.Errorf("output = %q, want 2 tokens", got)
+	}
+	if c := final.Completion; c == nil || c.StopReason != engine.StopMaxTokens || c.Timings.PredictedMs == 0 {
+		t.Errorf("final event = %+v", final)
+	}
+}
+
+func TestStartInferenceSampling(t *testing.T) {
+	e, model, fake := attached(t, enginetest.Script{Tokens: []string{"a"}})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	cfg.Raw = true
+	cfg.MinP, cfg.TypicalP = 0.05, 0.9
+	cfg.PresencePenalty, cfg.FrequencyPenalty = 0.5, -0.5
+	cfg.Mirostat, cfg.MirostatTau, cfg.MirostatEta = 2, 4, 0.2
+	cfg.LogitBias = map[int]float64{7: -100}
+
+	tokens, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(t, tokens)
+
+	req := fake.Requests()[0]
+	if req.MinP != 0.05 || req.TypicalP != 0.9 || req.PresencePenalty != 0.5 || req.FrequencyPenalty != -0.5 {
+		t.Errorf("min_p %g, typical_p %g, presence %g, frequency %g", req.MinP, req.TypicalP, req.PresencePenalty, req.FrequencyPenalty)
+	}
+	if req.Mirostat != 2 || req.MirostatTau != 4 || req.MirostatEta != 0.2 {
+		t.Errorf("mirostat %d, tau %g, eta %g", req.Mirostat, req.MirostatTau, req.MirostatEta)
+	}
+	if !reflect.DeepEqual(req.LogitBias, [][2]float64{{7, -100}}) {
+		t.Errorf("logit_bias = %v", req.LogitBias)
+	}
+}
+
+func TestStartInferenceInvalidConfig(t *testing.T) {
+	e, model, fake := attached(t, enginetest.Script{Tokens: []string{"a"}})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	cfg.Temperature = 5
+	if _, err := e.StartInference(context.Background(), cfg); !errors.Is(err, engine.ErrInvalidConfig) {
+		t.Errorf("err = %v, want ErrInvalidConfig", err)
+	}
+	if len(fake.Requests()) != 0 {
+		t.Error("invalid request reached the engine")
+	}
+}
+
+func TestStartInferenceEngineError(t *testing.T) {
+	e, model, _ := attached(t, enginetest.Script{CompletionStatus: 400, CompletionError: "the request exceeds the context size"})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	_, err := e.StartInference(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the context size") {
+		t.Errorf("err = %v, want the engine's message", err)
+	}
+}
+
+func TestStartInferenceStreamCut(t *testing.T) {
+	e, model, _ := attached(t, enginetest.Script{Tokens: []string{"a", "b", "c"}, CutAfter: 2})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	tokens, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, final := collect(t, tokens)
+	if got != "ab" || final.Type != engine.StreamError || !errors.Is(final.Err, engine.ErrStreamCut) {
+		t.Errorf("output %q ended with %+v, want an error after 2 tokens", got, final)
+	}
+}
+
+// slowTokens is a long generation for cancellation tests
+var slowTokens = enginetest.Script{
+	Tokens:     strings.Split(strings.Repeat("x", 100), ""),
+	TokenDelay: 20 * time.Millisecond,
+}
+
+func TestCancelIsolatesRequests(t *testing.T) {
+	e, model, _ := attached(t, slowTokens)
+
+	start := func(id string) <-chan engine.StreamEvent {
+		cfg := engine.DefaultConfig()
+		cfg.ModelPath = model
+		cfg.Prompt = "x"
+		cfg.RequestID = id
+		tokens, err := e.StartInference(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-tokens
+		return tokens
+	}
+	first, second := start("first"), start("second")
+
+	if got := e.Requests(); len(got) != 2 || got[0].ID != "first" || got[1].ID != "second" {
+		t.Fatalf("Requests() = %+v", got)
+	}
+	if err := e.Cancel("first"); err != nil {
+		t.Fatal(err)
+	}
+	got, final := collect(t, first)
+	if len(got) >= 99 {
+		t.Errorf("cancelled request continued: %d tokens", len(got)+1)
+	}
+	if final.Completion == nil || final.Completion.StopReason != engine.StopCancelled {
+		t.Errorf("cancelled request ended with %+v", final)
+	}
+	got, final = collect(t, second)
+	if len(got) != 99 {
+		t.Errorf("other request got %d tokens, want all 100", len(got)+1)
+	}
+	if final.Completion == nil || final.Completion.StopReason != engine.StopEOS {
+		t.Errorf("other request ended with %+v", final)
+	}
+
+	if err := e.Cancel("first"); !errors.Is(err, engine.ErrRequestNotFound) {
+		t.Errorf("Cancel of a finished request = %v", err)
+	}
+	if got := e.Requests(); len(got) != 0 {
+		t.Errorf("finished requests still registered: %+v", got)
+	}
+}
+
+func TestContextCancelsRequest(t *testing.T) {
+	e, model, _ := attached(t, slowTokens)
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	ctx, cancel := context.WithCancel(context.Background())
+	tokens, err := e.StartInference(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-tokens
+	cancel()
+
+	// The stream closes even though nobody reads it
+	time.Sleep(100 * time.Millisecond)
+	if got, _ := collect(t, tokens); len(got) > 1 {
+		t.Errorf("got %d tokens after cancel", len(got))
+	}
+	if _, err := e.StartInference(ctx, cfg); !errors.Is(err, context.Canceled) {
+		t.Errorf("start with a cancelled context = %v", err)
+	}
+}
+
+func TestDuplicateRequestID(t *testing.T) {
+	e, model, _ := attached(t, slowTokens)
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	cfg.RequestID = "same"
+	tokens, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.StartInference(context.Background(), cfg); err == nil {
+		t.Error("second request with the same ID accepted")
+	}
+	e.Stop()
+	collect(t, tokens)
+}
+
+func TestTokenizeAndEmbed(t *testing.T) {
+	e, model, _ := attached(t, enginetest.Script{})
+
+	tokens, err := e.Tokenize(model, "one two three")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tokens, []int{1, 2, 3}) {
+		t.Errorf("Tokenize = %v", tokens)
+	}
+
+	vec, err := e.Embed(model, "abcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vec, []float32{4, 2, 1}) {
+		t.Errorf("Embed = %v", vec)
+	}
+
+	if err := e.Health(model); err != nil {
+		t.Errorf("Health = %v", err)
+	}
+}
+
+func TestLoadWaitsForHealth(t *testing.T) {
+	e, model, _ := attached(t, enginetest.Script{LoadDelay: 500 * time.Millisecond})
+
+	start := time.Now()
+	if err := e.LoadModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("LoadModel returned after %s, before the model was loaded", elapsed)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  enginetest.Script
+		timeout time.Duration
+		want    error
+		reason  string
+	}{
+		{"model load fails", enginetest.Script{LoadError: "failed to load model"}, 0, engine.ErrModelLoadFailed, "failed to load model"},
+		{"engine exits", enginetest.Script{ExitOnStart: true}, 0, engine.ErrEngineExited, "exit status 1"},
+		{"load times out", enginetest.Script{LoadDelay: time.Hour}, time.Second, engine.ErrLoadTimeout, "not ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := spawned(t, tt.script, engine.PoolConfig{MaxModels: 1, LoadTimeout: tt.timeout})
+			events, unsubscribe := e.Subscribe()
+			defer unsubscribe()
+
+			err := e.LoadModel(filepath.Join(t.TempDir(), "tiny.gguf"))
+			var loadErr *engine.LoadError
+			if !errors.Is(err, tt.want) || !errors.As(err, &loadErr) || !strings.Contains(loadErr.Reason, tt.reason) {
+				t.Fatalf("err = %v, want %v with %q", err, tt.want, tt.reason)
+			}
+			if len(e.Loaded()) != 0 {
+				t.Error("failed model is still in the pool")
+			}
+			if ev := waitEvent(t, events, engine.EventFailed); ev.Reason == "" {
+				t.Error("failed event has no reason")
+			}
+		})
+	}
+}
+
+func TestEngineOutputIsLogged(t *testing.T) {
+	e := spawned(t, enginetest.Script{ExitOnStart: true}, engine.PoolConfig{MaxModels: 1})
+
+	err := e.LoadModel(filepath.Join(t.TempDir(), "tiny.gguf"))
+	var loadErr *engine.LoadError
+	if !errors.As(err, &loadErr) || !reflect.DeepEqual(loadErr.Tail, []string{"error: failed to load model"}) {
+		t.Fatalf("err = %v, want the engine output in the tail", err)
+	}
+	lines := e.Logs().Tail(0)
+	if len(lines) == 0 || lines[len(lines)-1].Text != "error: failed to load model" || lines[0].Model != "tiny.gguf" {
+		t.Errorf("logs = %v", lines)
+	}
+}
+
+// engineArgs returns the command line the fake engine last logged
+func engineArgs(t *testing.T, e *engine.Executor) string {
+	t.Helper()
+	var args string
+	for _, l := range e.Logs().Tail(0) {
+		if rest, ok := strings.CutPrefix(l.Text, "fake engine args: "); ok {
+			args = rest
+		}
+	}
+	if args == "" {
+		t.Fatal("the engine's command line was not logged")
+	}
+	return args
+}
+
+func TestLoadOptions(t *testing.T) {
+	e := spawned(t, enginetest.Script{Tokens: []string{"a"}}, engine.PoolConfig{
+		MaxModels: 1,
+		Load:      engine.LoadOptions{Threads: 3, ContextSize: 1024, MLock: true},
+	})
+	model := filepath.Join(t.TempDir(), "tiny.gguf")
+
+	if err := e.LoadModel(model); err != nil {
+		t.Fatal(err)
+	}
+	args := engineArgs(t, e)
+	for _, want := range []string{"-t 3", "-c 1024", "--mlock"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("engine args %q lack %q", args, want)
+		}
+	}
+	if strings.Contains(args, "--no-mmap") {
+		t.Errorf("engine args %q have --no-mmap", args)
+	}
+
+	// A request with other load-time settings reloads the idle model
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	cfg.Raw = true
+	cfg.ContextSize = 4096
+	cfg.NoMMap = ptr(true)
+	tokens, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(t, tokens)
+
+	args = engineArgs(t, e)
+	for _, want := range []string{"-t 3", "-c 4096", "--mlock", "--no-mmap"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("reloaded engine args %q lack %q", args, want)
+		}
+	}
+	loaded := e.Loaded()
+	if len(loaded) != 1 || loaded[0].Options != (engine.LoadOptions{Threads: 3, ContextSize: 4096, MLock: true, NoMMap: true}) {
+		t.Errorf("loaded = %+v", loaded)
+	}
+
+	// An explicit false turns off a switch the pool has on
+	cfg.MLock = ptr(false)
+	tokens, err = e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(t, tokens)
+	if args = engineArgs(t, e); strings.Contains(args, "--mlock") || !strings.Contains(args, "--no-mmap") {
+		t.Errorf("engine args %q, want --no-mmap without --mlock", args)
+	}
+}
+
+func TestCrashRestarts(t *testing.T) {
+	e := spawned(t, enginetest.Script{Tokens: []string{"a", "b", "c"}, CrashAfter: 1}, engine.PoolConfig{MaxModels: 1})
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+
+	model := filepath.Join(t.TempDir(), "tiny.gguf")
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	cfg.Raw = true
+	tokens, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, final := collect(t, tokens)
+	if got != "a" {
+		t.Errorf("output = %q, want the tokens sent before the crash", got)
+	}
+	if final.Type != engine.StreamError || !errors.Is(final.Err, engine.ErrStreamCut) {
+		t.Errorf("final event = %+v, want the stream reported as cut", final)
+	}
+
+	waitEvent(t, events, engine.EventCrashed)
+	if ev := waitEvent(t, events, engine.EventRestarting); ev.Attempt != 1 {
+		t.Errorf("restart attempt = %d, want 1", ev.Attempt)
+	}
+	waitEvent(t, events, engine.EventReady)
+
+	crashes := e.Crashes()
+	if len(crashes) != 1 || crashes[0].Model != model {
+		t.Fatalf("crashes = %+v", crashes)
+	}
+	loaded := e.Loaded()
+	if len(loaded) != 1 || loaded[0].Restarts != 1 || loaded[0].State != "ready" {
+		t.Errorf("loaded = %+v", loaded)
+	}
+}
+
+func TestPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	e := spawned(t, enginetest.Script{}, engine.PoolConfig{MaxModels: 2})
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a.gguf"), filepath.Join(dir, "b.gguf"), filepath.Join(dir, "c.gguf")
+
+	for _, model := range []string{a, b, a, c} {
+		if err := e.LoadModel(model); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var loaded []string
+	for _, m := range e.Loaded() {
+		loaded = append(loaded, m.ModelPath)
+		if m.Backend != engine.BackendLlamaServer || m.Port == "" {
+			t.Errorf("%s: backend %q, port %q", m.ModelPath, m.Backend, m.Port)
+		}
+	}
+	if len(loaded) != 2 || strings.Contains(strings.Join(loaded, " "), b) {
+		t.Errorf("loaded = %v, want a and c", loaded)
+	}
+}
+
+func TestPoolWaitsForBusyModel(t *testing.T) {
+	script := enginetest.Script{Tokens: strings.Split(strings.Repeat("x", 20), ""), TokenDelay: 20 * time.Millisecond}
+	e := spawned(t, script, engine.PoolConfig{MaxModels: 1})
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.gguf"), filepath.Join(dir, "b.gguf")
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = a
+	cfg.Prompt = "x"
+	stream, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-stream
+
+	// Loading b has to wait for the generation on a instead of killing it
+	loaded := make(chan error, 1)
+	go func() { loaded <- e.LoadModel(b) }()
+	got, final := collect(t, stream)
+	if len(got) != 19 || final.Completion == nil || final.Completion.StopReason != engine.StopEOS {
+		t.Errorf("generation on the busy model got %d tokens, ended with %+v", len(got)+1, final)
+	}
+	if err := <-loaded; err != nil {
+		t.Fatal(err)
+	}
+	if m := e.Loaded(); len(m) != 1 || m[0].ModelPath != b {
+		t.Errorf("loaded = %+v, want b", m)
+	}
+}
+
+func TestPoolMemoryBudget(t *testing.T) {
+	dir := t.TempDir()
+	big := modeltest.WriteGGUF(t, filepath.Join(dir, "big.gguf"), map[string]any{"general.name": strings.Repeat("x", 4096)})
+
+	e := spawned(t, enginetest.Script{}, engine.PoolConfig{MaxModels: 2, MemoryBudget: 1024})
+	if err := e.LoadModel(big); !errors.Is(err, engine.ErrMemoryBudget) {
+		t.Errorf("err = %v, want ErrMemoryBudget", err)
+	}
+}
+
+func waitEvent(t *testing.T, events <-chan engine.Event, want engine.EventType) engine.Event {
+	t.Helper()
+	timeout := time.After(15 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == want {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors reported when a model fails to load. They are wrapped in a
+// *LoadError, so check them with errors.Is.
+var (
+	ErrModelLoadFailed = errors.New("engine failed to load the model")
+	ErrEngineExited    = errors.New("engine exited before the model was loaded")
+	ErrLoadTimeout     = errors.New("timed out waiting for the model to load")
+)
+
+// DefaultLoadTimeout is the load time allowed per GiB of model weights
+const DefaultLoadTimeout = 60 * time.Second
+
+// LoadError describes why a model could not be loaded
+type LoadError struct {
+	Kind   error    // ErrModelLoadFailed, ErrEngineExited or ErrLoadTimeout
+	Model  string   // path of the model file
+	Reason string   // detail from the engine or the process exit status
+	Tail   []string // last lines of engine output
+}
+
+func (e *LoadError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %v", filepath.Base(e.Model), e.Kind)
+	if e.Reason != "" {
+		fmt.Fprintf(&b, ": %s", e.Reason)
+	}
+	if len(e.Tail) > 0 {
+		b.WriteString("\nengine output:\n  ")
+		b.WriteString(strings.Join(e.Tail, "\n  "))
+	}
+	return b.String()
+}
+
+func (e *LoadError) Unwrap() error { return e.Kind }
+
+// loadTimeout scales the per-GiB timeout by the model size, rounding up
+func loadTimeout(perGiB time.Duration, size int64) time.Duration {
+	if perGiB <= 0 {
+		perGiB = DefaultLoadTimeout
+	}
+	gib := (size + 1<<30 - 1) >> 30
+	return perGiB * time.Duration(max(gib, 1))
+}
+
+// healthState is the outcome of one /health probe
+type healthState int
+
+const (
+	healthStarting healthState = iota // not listening yet
+	healthLoading                     // listening, model still loading
+	healthReady
+	healthFailed
+)
+
+// healthBody covers both the old ({"status": "..."}) and the newer
+// ({"error": {"message": "..."}}) llama-server health responses
+type healthBody struct {
+	Status string `json:"status"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+var healthClient = &http.Client{Timeout: 2 * time.Second}
+
+// probeHealth asks the child server whether the model has loaded. The
+// returned string carries the engine's message when loading failed.
+func probeHealth(url string) (healthState, string) {
+	resp, err := healthClient.Get(url)
+	if err != nil {
+		return healthStarting, ""
+	}
+	defer resp.Body.Close()
+
+	var body healthBody
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	message := body.Status
+	if body.Error != nil {
+		message = body.Error.Message
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if body.Status == "error" {
+			return healthFailed, message
+		}
+		return healthReady, ""
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		// "no slot available" means loaded but busy
+		if body.Status == "no slot available" {
+			return healthReady, ""
+		}
+		return healthLoading, ""
+	default:
+		if message == "" {
+			message = resp.Status
+		}
+		return healthFailed, message
+	}
+}
+
+// tailBuffer keeps the last few lines written to it
+type tailBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	partial string
+	limit   int
+}
+
+func newTailBuffer(limit int) *tailBuffer {
+	return &tailBuffer{limit: limit}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	text := t.partial + string(p)
+	parts := strings.Split(text, "\n")
+	t.partial = parts[len(parts)-1]
+	for _, line := range parts[:len(parts)-1] {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		t.lines = append(t.lines, line)
+		if len(t.lines) > t.limit {
+			t.lines = t.lines[len(t.lines)-t.limit:]
+		}
+	}
+	return len(p), nil
+}
+
+// Lines returns a copy of the buffered lines, including an unterminated last line
+func (t *tailBuffer) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := append([]string(nil), t.lines...)
+	if strings.TrimSpace(t.partial) != "" {
+		lines = append(lines, t.partial)
+	}
+	if len(lines) > t.limit {
+		lines = lines[len(lines)-t.limit:]
+	}
+	return lines
+}
+
+// mentionsBind reports whether the engine output looks like a port clash
+func mentionsBind(lines []string) bool {
+	for _, line := range lines {
+		l := strings.ToLower(line)
+		if strings.Contains(l, "bind") || strings.Contains(l, "address already in use") {
+			return true
+		}
+	}
+	return false
+}
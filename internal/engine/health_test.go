@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProbeHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    healthState
+		message string
+	}{
+		{"ok", 200, `{"status":"ok"}`, healthReady, ""},
+		{"old error status", 200, `{"status":"error"}`, healthFailed, "error"},
+		{"loading", 503, `{"error":{"code":503,"message":"Loading model"}}`, healthLoading, ""},
+		{"busy", 503, `{"status":"no slot available"}`, healthReady, ""},
+		{"failed", 500, `{"error":{"code":500,"message":"failed to load model"}}`, healthFailed, "failed to load model"},
+		{"failed without body", 500, ``, healthFailed, "500 Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			state, message := probeHealth(srv.URL + "/health")
+			if state != tt.want || message != tt.message {
+				t.Errorf("probeHealth = %v, %q; want %v, %q", state, message, tt.want, tt.message)
+			}
+		})
+	}
+
+	t.Run("not listening", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		url := srv.URL
+		srv.Close()
+		if state, _ := probeHealth(url + "/health"); state != healthStarting {
+			t.Errorf("probeHealth = %v, want healthStarting", state)
+		}
+	})
+}
+
+func TestTailBuffer(t *testing.T) {
+	tail := newTailBuffer(3)
+	tail.Write([]byte("one\ntwo\r\n\n"))
+	tail.Write([]byte("thr"))
+	tail.Write([]byte("ee\nfour\nfi"))
+
+	want := []string{"three", "four", "fi"}
+	if got := tail.Lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lines() = %q, want %q", got, want)
+	}
+}
+
+func TestMentionsBind(t *testing.T) {
+	if !mentionsBind([]string{"main: loading", "couldn't bind HTTP server socket"}) {
+		t.Error("bind failure not detected")
+	}
+	if mentionsBind([]string{"error: failed to load model"}) {
+		t.Error("load failure taken for a bind failure")
+	}
+}
+
+func TestLoadTimeout(t *testing.T) {
+	tests := []struct {
+		perGiB time.Duration
+		size   int64
+		want   time.Duration
+	}{
+		{time.Second, 0, time.Second},
+		{time.Second, 1 << 30, time.Second},
+		{time.Second, 1<<30 + 1, 2 * time.Second},
+		{0, 3 << 30, 3 * DefaultLoadTimeout},
+	}
+	for _, tt := range tests {
+		if got := loadTimeout(tt.perGiB, tt.size); got != tt.want {
+			t.Errorf("loadTimeout(%s, %d) = %s, want %s", tt.perGiB, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	err := error(&LoadError{
+		Kind:   ErrEngineExited,
+		Model:  "/models/tiny.gguf",
+		Reason: "exit status 1",
+		Tail:   []string{"error: out of memory"},
+	})
+	if !errors.Is(err, ErrEngineExited) {
+		t.Error("errors.Is does not see the kind")
+	}
+	want := "tiny.gguf: engine exited before the model was loaded: exit status 1\nengine output:\n  error: out of memory"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
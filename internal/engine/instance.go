@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// instance is one model held by the pool, served by its backend
+type instance struct {
+	modelPath string
+	backend   Backend
+	kind      string // backend type, e.g. llama-server
+	size      int64  // estimated memory use in bytes
+	load      LoadOptions
+	unloaded  atomic.Bool
+
+	ready   chan struct{} // closed once loading has finished
+	loadErr error         // set before ready is closed
+
+	// Guarded by Executor.mu
+	active    int // in-flight requests
+	lastUsed  time.Time
+	state     instanceState
+	readyAt   time.Time
+	restarts  int    // restarts since the model last ran stably
+	lastCrash *Crash // crash that caused the current restart, if any
+}
+
+// instanceState is the lifecycle state reported for a loaded model
+type instanceState string
+
+const (
+	stateLoading    instanceState = "loading"
+	stateReady      instanceState = "ready"
+	stateRestarting instanceState = "restarting"
+)
+
+func (e *Executor) newInstance(modelPath string, load LoadOptions) *instance {
+	inst := &instance{
+		modelPath: modelPath,
+		backend:   e.newBackend(modelPath, load),
+		kind:      e.backendName(modelPath),
+		load:      load,
+		ready:     make(chan struct{}),
+		lastUsed:  time.Now(),
+		state:     stateLoading,
+		size:      e.modelSize(modelPath),
+	}
+	return inst
+}
+
+// modelSize estimates the memory a model takes once loaded. Remote models
+// take no memory here.
+func (e *Executor) modelSize(modelPath string) int64 {
+	if e.backendName(modelPath) == BackendRemote {
+		return 0
+	}
+	return estimateMemory(modelPath)
+}
+
+// isReady reports whether loading has finished (successfully or not)
+func (i *instance) isReady() bool {
+	select {
+	case <-i.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop unloads the model. The supervisor treats the exit as intentional.
+func (i *instance) stop() {
+	i.unloaded.Store(true)
+	_ = i.backend.Unload()
+}
+
+// estimateMemory approximates the RAM a loaded model needs. The weights are
+// memory-mapped in full, so the file size is a good lower bound.
+func estimateMemory(modelPath string) int64 {
+	info, err := os.Stat(modelPath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
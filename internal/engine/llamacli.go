@@ -0,0 +1,290 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// endOfText is how llama-cli marks the end of the model's reply
+const endOfText = " [end of text]"
+
+// llamaCLI runs a llama-cli process per generation, feeding the prompt
+// through a file and streaming stdout. Nothing stays in memory between
+// requests, which suits machines that cannot keep a server running.
+type llamaCLI struct {
+	binPath   string
+	modelPath string
+	load      LoadOptions
+	logs      *LogStore
+
+	ctx    context.Context // cancelled by Unload to stop running generations
+	cancel context.CancelFunc
+}
+
+func newLlamaCLI(binPath, modelPath string, load LoadOptions, logs *LogStore) *llamaCLI {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &llamaCLI{binPath: binPath, modelPath: modelPath, load: load, logs: logs, ctx: ctx, cancel: cancel}
+}
+
+// Load only checks that the binary and the model exist; the model is read
+// from disk again for every generation
+func (c *llamaCLI) Load(ctx context.Context) error {
+	if _, err := os.Stat(c.binPath); err != nil {
+		return &LoadError{Kind: ErrModelLoadFailed, Model: c.modelPath, Reason: "llama-cli not found: " + err.Error()}
+	}
+	if _, err := os.Stat(c.modelPath); err != nil {
+		return &LoadError{Kind: ErrModelLoadFailed, Model: c.modelPath, Reason: err.Error()}
+	}
+	return nil
+}
+
+func (c *llamaCLI) Unload() error {
+	c.cancel()
+	return nil
+}
+
+func (c *llamaCLI) Generate(ctx context.Context, r GenerateRequest) (<-chan StreamEvent, error) {
+	// A long conversation would not fit on the command line, where it would
+	// also be visible to other users
+	promptFile, err := writePromptFile(r.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{
+		"-m", c.modelPath,
+		"-f", promptFile,
+		"-n", strconv.Itoa(r.MaxTokens),
+		"-c", strconv.Itoa(c.load.contextSize()),
+		"--temp", formatFloat(r.Temperature),
+		"--top-p", formatFloat(r.TopP),
+		"--top-k", strconv.Itoa(r.TopK),
+		"--repeat-penalty", formatFloat(r.RepeatPenalty),
+		"--seed", strconv.Itoa(r.Seed),
+		"--no-display-prompt",
+	}
+	args = append(args, c.load.args()...)
+	args = append(args, samplingArgs(r)...)
+
+	// Stop on either the caller's context or Unload
+	runCtx, cancel := context.WithCancel(ctx)
+	stopOnUnload := context.AfterFunc(c.ctx, cancel)
+
+	cmd := exec.CommandContext(runCtx, c.binPath, args...)
+	cmd.Stderr = c.logs.Writer(c.modelPath)
+	configureProcess(cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		stopOnUnload()
+		cancel()
+		os.Remove(promptFile)
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		stopOnUnload()
+		cancel()
+		os.Remove(promptFile)
+		return nil, fmt.Errorf("failed to start llama-cli: %w", err)
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		defer os.Remove(promptFile)
+		defer stopOnUnload()
+		defer cancel()
+
+		// Not runCtx: it is cancelled to stop the process before the done event
+		send := func(ev StreamEvent) bool {
+			select {
+			case out <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			case <-c.ctx.Done():
+				return false
+			}
+		}
+
+		// llama-cli marks the end of generation in its output
+		filter := stopFilter{stops: append([]string{endOfText}, r.Stop...)}
+		buf := make([]byte, 4096)
+		for {
+			n, err := stdout.Read(buf)
+			if n > 0 {
+				text, done := filter.push(buf[:n])
+				if text != "" && !send(tokenEvent(text)) {
+					cmd.Wait()
+					return
+				}
+				if done {
+					// Stop reading; cancel kills the process before it is reaped
+					cancel()
+					cmd.Wait()
+					c := Completion{StopReason: StopEOS}
+					if filter.matched != endOfText {
+						c.StopReason = StopWord
+						c.StopWord = filter.matched
+					}
+					send(doneEvent(c))
+					return
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		if text := filter.flush(); text != "" && !send(tokenEvent(text)) {
+			cmd.Wait()
+			return
+		}
+
+		// Without the end marker, output stopped at the token limit or the
+		// process failed
+		if err := cmd.Wait(); err != nil {
+			if ctx.Err() == nil {
+				send(errorEvent(fmt.Errorf("llama-cli failed: %w", err)))
+			}
+			return
+		}
+		reason := StopEOS
+		if r.MaxTokens > 0 {
+			reason = StopMaxTokens
+		}
+		send(doneEvent(Completion{StopReason: reason}))
+	}()
+	return out, nil
+}
+
+// writePromptFile stores the prompt in a temporary file only the current
+// user can read, for llama-cli's -f flag
+func writePromptFile(prompt string) (string, error) {
+	f, err := os.CreateTemp("", "bitnet-prompt-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to write the prompt: %w", err)
+	}
+	_, err = f.WriteString(prompt)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write the prompt: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// samplingArgs are the llama-cli flags for the sampling settings that are set
+func samplingArgs(r GenerateRequest) []string {
+	var args []string
+	flag := func(name string, v float64) {
+		if v != 0 {
+			args = append(args, name, formatFloat(v))
+		}
+	}
+	flag("--min-p", r.MinP)
+	flag("--typical", r.TypicalP)
+	flag("--presence-penalty", r.PresencePenalty)
+	flag("--frequency-penalty", r.FrequencyPenalty)
+	if r.Mirostat != 0 {
+		args = append(args, "--mirostat", strconv.Itoa(r.Mirostat))
+		flag("--mirostat-ent", r.MirostatTau)
+		flag("--mirostat-lr", r.MirostatEta)
+	}
+
+	// Sorted so the command line is the same on every run
+	tokens := make([]int, 0, len(r.LogitBias))
+	for token := range r.LogitBias {
+		tokens = append(tokens, token)
+	}
+	sort.Ints(tokens)
+	for _, token := range tokens {
+		args = append(args, "--logit-bias", fmt.Sprintf("%d%+g", token, r.LogitBias[token]))
+	}
+	return args
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func (c *llamaCLI) Tokenize(ctx context.Context, text string) ([]int, error) {
+	return nil, fmt.Errorf("tokenize: %w", ErrUnsupported)
+}
+
+func (c *llamaCLI) Embed(ctx context.Context, text string) ([]float32, error) {
+	return nil, fmt.Errorf("embeddings: %w", ErrUnsupported)
+}
+
+func (c *llamaCLI) Health(ctx context.Context) error {
+	_, err := os.Stat(c.binPath)
+	return err
+}
+
+// stopFilter cuts raw process output at the first stop sequence. Text that
+// could be the start of a stop sequence, or of a multi-byte character, is
+// held back until the next chunk shows what it is.
+type stopFilter struct {
+	stops   []string
+	pending []byte
+	matched string // the stop sequence that was reached
+}
+
+// push adds output and returns the text that is safe to emit, and whether a
+// stop sequence was reached
+func (f *stopFilter) push(p []byte) (string, bool) {
+	f.pending = append(f.pending, p...)
+
+	text := string(f.pending)
+	cut := -1
+	for _, stop := range f.stops {
+		if stop == "" {
+			continue
+		}
+		if i := strings.Index(text, stop); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+			f.matched = stop
+		}
+	}
+	if cut >= 0 {
+		f.pending = nil
+		return text[:cut], true
+	}
+
+	// Keep back any suffix that is a prefix of a stop sequence
+	keep := 0
+	for _, stop := range f.stops {
+		for n := min(len(stop)-1, len(text)); n > keep; n-- {
+			if strings.HasSuffix(text, stop[:n]) {
+				keep = n
+				break
+			}
+		}
+	}
+	emit := len(text) - keep
+	// Never split a UTF-8 sequence
+	if emit > 0 {
+		start := emit - 1
+		for start > 0 && emit-start < utf8.UTFMax && !utf8.RuneStart(f.pending[start]) {
+			start--
+		}
+		if !utf8.FullRune(f.pending[start:emit]) {
+			emit = start
+		}
+	}
+	out := text[:emit]
+	f.pending = append([]byte(nil), f.pending[emit:]...)
+	return out, false
+}
+
+// flush returns whatever is still held back once the output has ended
+func (f *stopFilter) flush() string {
+	text := string(f.pending)
+	f.pending = nil
+	return text
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStopFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		stops  []string
+		chunks []string
+		want   string
+		done   bool
+	}{
+		{"no stop", []string{"</s>"}, []string{"Hello", ", world"}, "Hello, world", false},
+		{"stop in one chunk", []string{"</s>"}, []string{"Hi</s>ignored"}, "Hi", true},
+		{"stop split across chunks", []string{"</s>"}, []string{"Hi<", "/", "s>ignored"}, "Hi", true},
+		{"false start", []string{"</s>"}, []string{"a <", "b"}, "a <b", false},
+		{"earliest stop wins", []string{"B", "A"}, []string{"xAyB"}, "x", true},
+		{"end of text marker", []string{" [end of text]"}, []string{"done [end", " of text]\n"}, "done", true},
+		{"split rune", nil, []string{"caf\xc3", "\xa9"}, "café", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := stopFilter{stops: tt.stops}
+			var out strings.Builder
+			done := false
+			for _, chunk := range tt.chunks {
+				text, stop := f.push([]byte(chunk))
+				out.WriteString(text)
+				if stop {
+					done = true
+					break
+				}
+			}
+			if !done {
+				out.WriteString(f.flush())
+			}
+			if out.String() != tt.want || done != tt.done {
+				t.Errorf("got %q (done %v), want %q (done %v)", out.String(), done, tt.want, tt.done)
+			}
+		})
+	}
+}
+
+func TestStopFilterHoldsBackPartialRune(t *testing.T) {
+	f := stopFilter{}
+	if text, _ := f.push([]byte("caf\xc3")); text != "caf" {
+		t.Errorf("push = %q, want the complete runes only", text)
+	}
+}
+
+func TestStopFilterRemembersStop(t *testing.T) {
+	f := stopFilter{stops: []string{" [end of text]", "B", "A"}}
+	if _, done := f.push([]byte("xAyB")); !done || f.matched != "A" {
+		t.Errorf("matched = %q (done %v), want the earliest stop", f.matched, done)
+	}
+}
+
+func TestSamplingArgs(t *testing.T) {
+	got := samplingArgs(GenerateRequest{
+		MinP:        0.05,
+		Mirostat:    2,
+		MirostatTau: 5,
+		LogitBias:   map[int]float64{42: 1.5, 7: -100},
+	})
+	want := "--min-p 0.05 --mirostat 2 --mirostat-ent 5 --logit-bias 7-100 --logit-bias 42+1.5"
+	if strings.Join(got, " ") != want {
+		t.Errorf("samplingArgs = %q, want %q", strings.Join(got, " "), want)
+	}
+	if got := samplingArgs(GenerateRequest{MirostatTau: 5}); len(got) != 0 {
+		t.Errorf("mirostat settings without mirostat = %q", got)
+	}
+}
+
+func TestLlamaCLIPromptFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake llama-cli is a shell script")
+	}
+	dir := t.TempDir()
+	// Prompt files go to the temporary directory
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	// Echoes the prompt file; fails if the prompt is on the command line
+	bin := filepath.Join(dir, "llama-cli")
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -p) exit 2 ;;
+    -f) cat "$2" ;;
+  esac
+  shift
+done
+printf ' [end of text]\n'
+`
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(dir, "tiny.gguf")
+	os.WriteFile(model, nil, 0644)
+
+	c := newLlamaCLI(bin, model, LoadOptions{}, NewLogStore(""))
+	prompt := strings.Repeat("a long conversation ", 20000)
+	stream, err := c.Generate(context.Background(), GenerateRequest{Prompt: prompt, MaxTokens: 10, Seed: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	var last StreamEvent
+	for ev := range stream {
+		out.WriteString(ev.Token)
+		last = ev
+	}
+	if last.Type != StreamDone || out.String() != prompt {
+		t.Errorf("output has %d bytes (last event %+v), want the %d byte prompt", out.Len(), last, len(prompt))
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("prompt file left behind: %v", entries)
+	}
+}
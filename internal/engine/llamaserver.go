@@ -0,0 +1,430 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ServerRequest struct {
+	Prompt        string   `json:"prompt"`
+	NPredict      int      `json:"n_predict"`
+	Temperature   float64  `json:"temperature"`
+	TopP          float64  `json:"top_p"`
+	TopK          int      `json:"top_k"`
+	RepeatPenalty float64  `json:"repeat_penalty"`
+	Stop          []string `json:"stop,omitempty"`
+	Seed          int      `json:"seed"`
+	Stream        bool     `json:"stream"`
+
+	// Left out when zero so llama-server's defaults apply
+	MinP             float64      `json:"min_p,omitempty"`
+	TypicalP         float64      `json:"typical_p,omitempty"`
+	PresencePenalty  float64      `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64      `json:"frequency_penalty,omitempty"`
+	Mirostat         int          `json:"mirostat,omitempty"`
+	MirostatTau      float64      `json:"mirostat_tau,omitempty"`
+	MirostatEta      float64      `json:"mirostat_eta,omitempty"`
+	LogitBias        [][2]float64 `json:"logit_bias,omitempty"` // [token ID, bias] pairs
+}
+
+type ServerResponse struct {
+	Content string `json:"content"`
+	Stop    bool   `json:"stop"`
+
+	// Only set in the final chunk
+	StoppedEOS      bool    `json:"stopped_eos"`
+	StoppedWord     bool    `json:"stopped_word"`
+	StoppedLimit    bool    `json:"stopped_limit"`
+	StoppingWord    string  `json:"stopping_word"`
+	TokensEvaluated int     `json:"tokens_evaluated"`
+	TokensPredicted int     `json:"tokens_predicted"`
+	Timings         Timings `json:"timings"`
+}
+
+// completion reads the stop reason and counts from a final chunk
+func (r ServerResponse) completion() Completion {
+	c := Completion{
+		StopReason:      StopEOS,
+		TokensEvaluated: r.TokensEvaluated,
+		TokensPredicted: r.TokensPredicted,
+		Timings:         r.Timings,
+	}
+	switch {
+	case r.StoppedWord:
+		c.StopReason = StopWord
+		c.StopWord = r.StoppingWord
+	case r.StoppedLimit:
+		c.StopReason = StopMaxTokens
+	}
+	return c
+}
+
+// startAttempts is how often Load retries with a fresh port when the
+// engine could not bind (another process may have taken the port)
+const startAttempts = 3
+
+// tailLines is how much engine output is kept for load errors
+const tailLines = 20
+
+// llamaServer is the default backend: a llama-server child process serving
+// a single model over HTTP on a loopback port
+type llamaServer struct {
+	binPath   string
+	modelPath string
+	logs      *LogStore
+	attachURL string // an already running llama-server to use instead of starting one
+	parallel  int    // slots, so that many requests decode at the same time
+	load      LoadOptions
+
+	procMu  sync.Mutex // guards all fields below
+	cmd     *exec.Cmd
+	port    string        // loopback port picked when the process starts
+	stopped bool          // Unload was called
+	tail    *tailBuffer   // recent output of the current process
+	exited  chan struct{} // closed when the current process has exited
+	exitErr error         // set before exited is closed
+}
+
+func newLlamaServer(binPath, modelPath, attachURL string, parallel int, load LoadOptions, logs *LogStore) *llamaServer {
+	return &llamaServer{
+		binPath:   binPath,
+		modelPath: modelPath,
+		logs:      logs,
+		attachURL: strings.TrimRight(attachURL, "/"),
+		parallel:  max(parallel, 1),
+		load:      load,
+	}
+}
+
+// url returns the address of an endpoint on the child server
+func (s *llamaServer) url(path string) string {
+	if s.attachURL != "" {
+		return s.attachURL + path
+	}
+	s.procMu.Lock()
+	defer s.procMu.Unlock()
+	return fmt.Sprintf("http://127.0.0.1:%s%s", s.port, path)
+}
+
+// Port returns the loopback port the child server listens on
+func (s *llamaServer) Port() string {
+	s.procMu.Lock()
+	defer s.procMu.Unlock()
+	return s.port
+}
+
+// Load launches the child server on a free loopback port and waits for
+// the model to load
+func (s *llamaServer) Load(ctx context.Context) error {
+	if s.attachURL != "" {
+		return s.waitReady(ctx, nil, nil)
+	}
+
+	var err error
+	for attempt := 0; attempt < startAttempts; attempt++ {
+		var port string
+		if port, err = freeLoopbackPort(); err != nil {
+			return err
+		}
+		err = s.launch(ctx, port)
+		var loadErr *LoadError
+		if !errors.As(err, &loadErr) || loadErr.Kind != ErrEngineExited || !mentionsBind(loadErr.Tail) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *llamaServer) launch(ctx context.Context, port string) error {
+	// The context is shared between slots, so each one gets the full size
+	args := []string{
+		"-m", s.modelPath,
+		"--port", port,
+		"-c", strconv.Itoa(s.load.contextSize() * s.parallel),
+		"--parallel", strconv.Itoa(s.parallel),
+		"--host", "127.0.0.1",
+	}
+	args = append(args, s.load.args()...)
+
+	cmd := exec.Command(s.binPath, args...)
+	tail := newTailBuffer(tailLines)
+	output := io.MultiWriter(tail, s.logs.Writer(s.modelPath))
+	cmd.Stdout = output
+	cmd.Stderr = output
+	configureProcess(cmd)
+
+	s.procMu.Lock()
+	if s.stopped {
+		s.procMu.Unlock()
+		return fmt.Errorf("model was unloaded while starting")
+	}
+	if err := cmd.Start(); err != nil {
+		s.procMu.Unlock()
+		return fmt.Errorf("failed to start server: %w", err)
+	}
+	s.cmd = cmd
+	s.port = port
+	s.tail = tail
+	exited := make(chan struct{})
+	s.exited = exited
+	s.procMu.Unlock()
+
+	// Reap the process as soon as it exits so early failures are noticed
+	go func() {
+		s.exitErr = cmd.Wait()
+		close(exited)
+	}()
+
+	return s.waitReady(ctx, exited, tail)
+}
+
+// waitReady polls /health until the model has loaded, the process exits
+// or ctx expires. exited and tail are nil for an attached server.
+func (s *llamaServer) waitReady(ctx context.Context, exited <-chan struct{}, tail *tailBuffer) error {
+	fail := func(kind error, reason string) error {
+		var lines []string
+		if tail != nil {
+			lines = tail.Lines()
+		}
+		return &LoadError{Kind: kind, Model: s.modelPath, Reason: reason, Tail: lines}
+	}
+
+	// Wait for server health
+	started := time.Now()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-exited:
+			reason := "exited"
+			if s.exitErr != nil {
+				reason = s.exitErr.Error()
+			}
+			return fail(ErrEngineExited, reason)
+		case <-ctx.Done():
+			return fail(ErrLoadTimeout, fmt.Sprintf("not ready after %s", time.Since(started).Round(time.Second)))
+		case <-ticker.C:
+		}
+
+		switch state, message := probeHealth(s.url("/health")); state {
+		case healthReady:
+			return nil
+		case healthFailed:
+			return fail(ErrModelLoadFailed, message)
+		}
+	}
+}
+
+// Unload kills the child process, or prevents it from starting
+func (s *llamaServer) Unload() error {
+	s.procMu.Lock()
+	defer s.procMu.Unlock()
+
+	s.stopped = true
+	if s.cmd != nil && s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+		<-s.exited
+	}
+	return nil
+}
+
+// Exited is nil for an attached server, which the executor does not supervise
+func (s *llamaServer) Exited() <-chan struct{} {
+	s.procMu.Lock()
+	defer s.procMu.Unlock()
+	return s.exited
+}
+
+func (s *llamaServer) ExitReason() (string, []string) {
+	s.procMu.Lock()
+	tail := s.tail
+	s.procMu.Unlock()
+
+	reason := "exited"
+	if s.exitErr != nil {
+		reason = s.exitErr.Error()
+	}
+	if tail == nil {
+		return reason, nil
+	}
+	return reason, tail.Lines()
+}
+
+func (s *llamaServer) Generate(ctx context.Context, r GenerateRequest) (<-chan StreamEvent, error) {
+	reqBody := ServerRequest{
+		Prompt:        r.Prompt,
+		NPredict:      r.MaxTokens,
+		Temperature:   r.Temperature,
+		TopP:          r.TopP,
+		TopK:          r.TopK,
+		RepeatPenalty: r.RepeatPenalty,
+		Stop:          r.Stop,
+		Seed:          r.Seed,
+		Stream:        true,
+
+		MinP:             r.MinP,
+		TypicalP:         r.TypicalP,
+		PresencePenalty:  r.PresencePenalty,
+		FrequencyPenalty: r.FrequencyPenalty,
+		Mirostat:         r.Mirostat,
+		MirostatTau:      r.MirostatTau,
+		MirostatEta:      r.MirostatEta,
+	}
+	for token, bias := range r.LogitBias {
+		reqBody.LogitBias = append(reqBody.LogitBias, [2]float64{float64(token), bias})
+	}
+
+	jsonData, _ := json.Marshal(reqBody)
+
+	// Create request with Context
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url("/completion"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connection failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var body healthBody
+		if json.NewDecoder(resp.Body).Decode(&body) == nil && body.Error != nil {
+			return nil, fmt.Errorf("completion failed: %s", body.Error.Message)
+		}
+		return nil, fmt.Errorf("completion failed: %s", resp.Status)
+	}
+
+	outputChan := make(chan StreamEvent)
+
+	go func() {
+		defer close(outputChan)
+		defer resp.Body.Close()
+
+		send := func(ev StreamEvent) bool {
+			select {
+			case outputChan <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			jsonStr := strings.TrimPrefix(line, "data: ")
+			if strings.Contains(jsonStr, "[DONE]") {
+				send(doneEvent(Completion{StopReason: StopEOS}))
+				return
+			}
+			var data ServerResponse
+			if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+				send(errorEvent(fmt.Errorf("invalid chunk from the engine: %w", err)))
+				return
+			}
+			// The final event carries no text, only stop: true and the counts
+			if data.Content != "" && !send(tokenEvent(data.Content)) {
+				return
+			}
+			if data.Stop {
+				send(doneEvent(data.completion()))
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err := scanner.Err(); err != nil {
+			send(errorEvent(fmt.Errorf("%w: %v", ErrStreamCut, err)))
+			return
+		}
+		send(errorEvent(ErrStreamCut))
+	}()
+
+	return outputChan, nil
+}
+
+func (s *llamaServer) Tokenize(ctx context.Context, text string) ([]int, error) {
+	var data struct {
+		Tokens []int `json:"tokens"`
+	}
+	if err := s.post(ctx, "/tokenize", map[string]string{"content": text}, &data); err != nil {
+		return nil, fmt.Errorf("tokenize failed: %w", err)
+	}
+	return data.Tokens, nil
+}
+
+func (s *llamaServer) Embed(ctx context.Context, text string) ([]float32, error) {
+	var data struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := s.post(ctx, "/embedding", map[string]string{"content": text}, &data); err != nil {
+		return nil, fmt.Errorf("embedding failed (the engine must run with embeddings enabled): %w", err)
+	}
+	return data.Embedding, nil
+}
+
+func (s *llamaServer) Health(ctx context.Context) error {
+	switch state, message := probeHealth(s.url("/health")); state {
+	case healthReady:
+		return nil
+	case healthLoading:
+		return fmt.Errorf("model is still loading")
+	case healthFailed:
+		return fmt.Errorf("%w: %s", ErrModelLoadFailed, message)
+	default:
+		return fmt.Errorf("engine is not responding")
+	}
+}
+
+// post sends a JSON request to the child server and decodes the JSON reply
+func (s *llamaServer) post(ctx context.Context, path string, body, out any) error {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url(path), bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("connection failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+// freeLoopbackPort asks the OS for an unused TCP port on 127.0.0.1
+func freeLoopbackPort() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("no free loopback port for the engine: %w", err)
+	}
+	defer l.Close()
+	return strconv.Itoa(l.Addr().(*net.TCPAddr).Port), nil
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogFile is the name of the engine log inside the log directory
+const LogFile = "engine.log"
+
+// MaxLogLines is the number of recent lines a LogStore keeps in memory
+const MaxLogLines = logLines
+
+// Log retention
+const (
+	logLines      = 2000    // lines kept in memory
+	logMaxSize    = 5 << 20 // bytes before engine.log is rotated
+	logMaxBackups = 3       // rotated files kept (engine.log.1 ... engine.log.3)
+)
+
+// LogLine is one line of output from a child server
+type LogLine struct {
+	Time  time.Time `json:"time"`
+	Model string    `json:"model"` // file name of the model the process serves
+	Text  string    `json:"text"`
+}
+
+func (l LogLine) String() string {
+	return fmt.Sprintf("%s [%s] %s", l.Time.Format(time.RFC3339), l.Model, l.Text)
+}
+
+// LogStore collects the output of all child servers into an in-memory
+// ring buffer and, when a directory is configured, a rotating log file
+type LogStore struct {
+	mu    sync.Mutex
+	lines []LogLine
+	file  *rotatingFile
+	subs  broadcaster[LogLine]
+}
+
+// NewLogStore creates a log store writing to dir/engine.log. An empty dir
+// keeps logs in memory only.
+func NewLogStore(dir string) *LogStore {
+	l := &LogStore{}
+	if dir != "" {
+		l.file = &rotatingFile{path: filepath.Join(dir, LogFile)}
+	}
+	return l
+}
+
+// Tail returns up to n of the most recent lines, oldest first. n <= 0 returns all.
+func (l *LogStore) Tail(n int) []LogLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lines := l.lines
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append([]LogLine(nil), lines...)
+}
+
+// Follow returns a channel of new lines and a function that stops following
+func (l *LogStore) Follow() (<-chan LogLine, func()) {
+	return l.subs.subscribe()
+}
+
+// Writer returns a writer that records output from the process serving model
+func (l *LogStore) Writer(model string) io.Writer {
+	return &lineWriter{store: l, model: filepath.Base(model)}
+}
+
+func (l *LogStore) add(line LogLine) {
+	l.mu.Lock()
+	l.lines = append(l.lines, line)
+	if len(l.lines) > 2*logLines {
+		l.lines = append([]LogLine(nil), l.lines[len(l.lines)-logLines:]...)
+	}
+	if l.file != nil {
+		// Logging must never take the engine down, so write errors are dropped
+		_ = l.file.writeLine(line.String())
+	}
+	l.mu.Unlock()
+
+	l.subs.publish(line)
+}
+
+// lineWriter splits process output into lines for a LogStore
+type lineWriter struct {
+	store   *LogStore
+	model   string
+	mu      sync.Mutex
+	partial string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	parts := strings.Split(w.partial+string(p), "\n")
+	w.partial = parts[len(parts)-1]
+	for _, text := range parts[:len(parts)-1] {
+		text = strings.TrimRight(text, "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		w.store.add(LogLine{Time: time.Now(), Model: w.model, Text: text})
+	}
+	return len(p), nil
+}
+
+// rotatingFile appends lines to a file, rotating it once it grows past logMaxSize
+type rotatingFile struct {
+	path string
+	f    *os.File
+	size int64
+}
+
+func (r *rotatingFile) writeLine(line string) error {
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	if r.size+int64(len(line))+1 > logMaxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := fmt.Fprintln(r.f, line)
+	r.size += int64(n)
+	return err
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	r.f = nil
+
+	for i := logMaxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogStore(t *testing.T) {
+	dir := t.TempDir()
+	logs := NewLogStore(dir)
+	follow, stop := logs.Follow()
+	defer stop()
+
+	w := logs.Writer("/models/tiny.gguf")
+	w.Write([]byte("loading\nlistening on 127.0.0.1:8080\npart"))
+
+	tail := logs.Tail(1)
+	if len(tail) != 1 || tail[0].Text != "listening on 127.0.0.1:8080" || tail[0].Model != "tiny.gguf" {
+		t.Fatalf("Tail(1) = %v", tail)
+	}
+	if got := len(logs.Tail(0)); got != 2 {
+		t.Errorf("Tail(0) returned %d lines, want 2 (the unterminated line is held back)", got)
+	}
+	if line := <-follow; line.Text != "loading" {
+		t.Errorf("first followed line = %q", line.Text)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, LogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "[tiny.gguf] loading\n") {
+		t.Errorf("%s does not contain the output:\n%s", LogFile, data)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LogFile)
+	r := &rotatingFile{path: path}
+	defer func() { r.f.Close() }()
+
+	line := strings.Repeat("x", logMaxSize/3)
+	for i := 0; i < 3*(logMaxBackups+2); i++ {
+		if err := r.writeLine(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i <= logMaxBackups; i++ {
+		if _, err := os.Stat(fmt.Sprintf("%s.%d", path, i)); err != nil {
+			t.Errorf("backup %d missing: %v", i, err)
+		}
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s.%d", path, logMaxBackups+1)); !os.IsNotExist(err) {
+		t.Errorf("more than %d backups kept", logMaxBackups)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() > logMaxSize {
+		t.Errorf("%s not rotated: %v", LogFile, err)
+	}
+}
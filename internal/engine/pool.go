@@ -0,0 +1,247 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/utils"
+)
+
+// ErrMemoryBudget is returned when a model cannot fit in the pool's memory budget
+var ErrMemoryBudget = errors.New("model does not fit in the memory budget")
+
+// PoolConfig limits how many models the executor keeps loaded at once
+type PoolConfig struct {
+	MaxModels    int           // child servers kept alive at the same time
+	MemoryBudget int64         // total estimated bytes for loaded models; 0 means unlimited
+	LoadTimeout  time.Duration // load time allowed per GiB of weights; 0 uses DefaultLoadTimeout
+	LogDir       string        // directory for engine.log; empty keeps engine output in memory only
+
+	Parallel   int    // generations per model at the same time (llama-server slots); 0 means 1
+	QueueLimit int    // requests allowed to wait per model; 0 means unlimited
+	QueueOrder string // QueueFIFO (default) or QueuePriority
+
+	// Load holds the engine settings models are loaded with unless a
+	// request asks for others
+	Load LoadOptions
+
+	// Models selects a backend per model, keyed by file name
+	Models map[string]config.ModelConfig
+}
+
+// DefaultPoolConfig keeps a single model loaded, like a desktop session needs
+func DefaultPoolConfig() PoolConfig {
+	cfg := PoolConfig{
+		MaxModels:   1,
+		LoadTimeout: DefaultLoadTimeout,
+		Parallel:    1,
+		QueueOrder:  QueueFIFO,
+	}
+	if dir, err := utils.GetLogsDir(); err == nil {
+		cfg.LogDir = dir
+	}
+	if file, err := config.Load(); err == nil {
+		cfg.Models = file.Models
+	}
+	return cfg
+}
+
+// LoadedModel describes one model currently held by the pool
+type LoadedModel struct {
+	ModelPath string      `json:"model_path"`
+	Backend   string      `json:"backend"`
+	Port      string      `json:"port,omitempty"` // llama-server backend only
+	Size      int64       `json:"size"`
+	Options   LoadOptions `json:"options"` // settings the model was loaded with
+	Active    int         `json:"active_requests"`
+	LastUsed  time.Time   `json:"last_used"`
+	State     string      `json:"state"` // loading, ready or restarting
+	Restarts  int         `json:"restarts"`
+	LastCrash *Crash      `json:"last_crash,omitempty"`
+}
+
+// Loaded lists the models currently held by the pool
+func (e *Executor) Loaded() []LoadedModel {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]LoadedModel, 0, len(e.instances))
+	for _, inst := range e.instances {
+		m := LoadedModel{
+			ModelPath: inst.modelPath,
+			Backend:   inst.kind,
+			Size:      inst.size,
+			Options:   inst.load,
+			Active:    inst.active,
+			LastUsed:  inst.lastUsed,
+			State:     string(inst.state),
+			Restarts:  inst.restarts,
+			LastCrash: inst.lastCrash,
+		}
+		// The port is only settled once loading has finished
+		if p, ok := inst.backend.(interface{ Port() string }); ok && inst.isReady() {
+			m.Port = p.Port()
+		}
+		list = append(list, m)
+	}
+	return list
+}
+
+// acquire returns a ready instance serving modelPath, loading the model
+// (and evicting least recently used idle ones) if needed. When every loaded
+// model is busy or still loading, acquire waits for one to become idle. With
+// opts, a model that is idle but was loaded with other settings is reloaded;
+// nil takes the model as it is. Every successful call must be paired with
+// release. Giving up on ctx does not abort the load, which other requests may
+// be waiting for.
+func (e *Executor) acquire(ctx context.Context, modelPath string, opts *LoadOptions) (*instance, error) {
+	load := e.pool.Load
+	if opts != nil {
+		load = *opts
+	}
+
+	var inst *instance
+	var ok bool
+	var stale []*instance
+	for {
+		stale = nil
+		e.mu.Lock()
+		inst, ok = e.instances[modelPath]
+		if ok && opts != nil && inst.load != load && inst.isReady() && inst.active == 0 {
+			delete(e.instances, modelPath)
+			stale = append(stale, inst)
+			ok = false
+		}
+		if ok {
+			break
+		}
+		victims, err := e.makeRoomLocked(e.modelSize(modelPath))
+		stale = append(stale, victims...)
+		if err == nil {
+			inst = e.newInstance(modelPath, load)
+			e.instances[modelPath] = inst
+			break
+		}
+		idle := e.idleLocked()
+		e.mu.Unlock()
+		// Stopping waits for the process to exit, which must not block the executor
+		stopAll(stale)
+		if !errors.Is(err, errPoolBusy) {
+			return nil, err
+		}
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	inst.active++
+	inst.lastUsed = time.Now()
+	e.mu.Unlock()
+	stopAll(stale)
+
+	// The request that created the instance starts loading it; all wait
+	if !ok {
+		go e.load(inst)
+	}
+	select {
+	case <-inst.ready:
+	case <-ctx.Done():
+		e.release(inst)
+		return nil, ctx.Err()
+	}
+
+	if inst.loadErr != nil {
+		e.release(inst)
+		return nil, inst.loadErr
+	}
+	return inst, nil
+}
+
+// release marks a request on the instance as finished
+func (e *Executor) release(inst *instance) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	inst.active--
+	inst.lastUsed = time.Now()
+	if inst.active == 0 {
+		e.notifyIdleLocked()
+	}
+}
+
+// errPoolBusy means no loaded model can be evicted right now
+var errPoolBusy = errors.New("all loaded models are busy")
+
+// idleLocked returns a channel closed the next time a loaded model may have
+// become evictable: a request finished, a load ended or a model was dropped
+func (e *Executor) idleLocked() <-chan struct{} {
+	if e.idle == nil {
+		e.idle = make(chan struct{})
+	}
+	return e.idle
+}
+
+// notifyIdleLocked wakes the requests waiting in acquire for room in the pool
+func (e *Executor) notifyIdleLocked() {
+	if e.idle != nil {
+		close(e.idle)
+		e.idle = nil
+	}
+}
+
+// makeRoomLocked removes idle models from the pool, least recently used
+// first, until one more model of the given size fits within the pool limits.
+// The removed instances are returned for the caller to stop once e.mu is
+// released, even along with an error. Busy models are never evicted, which
+// would cut off their generations; errPoolBusy means the caller has to wait.
+func (e *Executor) makeRoomLocked(size int64) ([]*instance, error) {
+	budget := e.pool.MemoryBudget
+	if budget > 0 && size > budget {
+		return nil, fmt.Errorf("%w: needs %d MB, budget is %d MB", ErrMemoryBudget, size>>20, budget>>20)
+	}
+
+	maxModels := max(e.pool.MaxModels, 1)
+	var victims []*instance
+	for {
+		used := int64(0)
+		for _, inst := range e.instances {
+			used += inst.size
+		}
+		if len(e.instances) < maxModels && (budget == 0 || used+size <= budget) {
+			return victims, nil
+		}
+
+		victim := e.evictionCandidateLocked()
+		if victim == nil {
+			return victims, errPoolBusy
+		}
+		delete(e.instances, victim.modelPath)
+		e.dropQueueLocked(victim.modelPath)
+		victims = append(victims, victim)
+	}
+}
+
+// evictionCandidateLocked picks the least recently used loaded instance that
+// has no request in flight. Instances that are still loading are never chosen.
+func (e *Executor) evictionCandidateLocked() *instance {
+	var idle *instance
+	for _, inst := range e.instances {
+		if !inst.isReady() || inst.active > 0 {
+			continue
+		}
+		if idle == nil || inst.lastUsed.Before(idle.lastUsed) {
+			idle = inst
+		}
+	}
+	return idle
+}
+
+// stopAll unloads instances already removed from the pool
+func stopAll(instances []*instance) {
+	for _, inst := range instances {
+		inst.stop()
+	}
+}
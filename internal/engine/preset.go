@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+)
+
+// ErrUnknownPreset is returned for a preset that is neither built in nor in the config file
+var ErrUnknownPreset = errors.New("unknown preset")
+
+// builtinPresets are always available; the config file may replace them
+func builtinPresets() map[string]config.Params {
+	return map[string]config.Params{
+		// Focused, repeatable answers
+		"precise": {
+			Temperature: ref(0.2),
+			TopP:        ref(0.8),
+			TopK:        ref(20),
+		},
+		// Varied, imaginative text
+		"creative": {
+			Temperature:   ref(1.1),
+			TopP:          ref(0.95),
+			TopK:          ref(100),
+			MinP:          ref(0.05),
+			RepeatPenalty: ref(1.15),
+		},
+		// Source code repeats itself, so it is not penalised
+		"code": {
+			Temperature:   ref(0.2),
+			TopP:          ref(0.95),
+			TopK:          ref(40),
+			RepeatPenalty: ref(1.0),
+			MaxTokens:     ref(1024),
+		},
+	}
+}
+
+// Presets returns the built-in presets together with those of the config
+// file, which win over built-in ones of the same name
+func Presets(file *config.Config) map[string]config.Params {
+	presets := builtinPresets()
+	if file != nil {
+		for name, p := range file.Presets {
+			presets[name] = p
+		}
+	}
+	return presets
+}
+
+// ResolveConfig returns the configuration a generation on model starts
+// from: DefaultConfig, then the preset, then the model's defaults from the
+// config file. For a model defined by a Modelfile, the defaults of its base
+// come before the Modelfile's settings and its own defaults after them.
+// Callers apply the request's own settings on top. An empty preset uses the
+// model's preset, if it names one.
+func ResolveConfig(file *config.Config, model models.ModelInfo, preset string) (InferenceConfig, error) {
+	cfg := DefaultConfig()
+	cfg.ModelPath = model.FilePath
+
+	if file == nil {
+		file = &config.Config{}
+	}
+	layers := []config.ModelConfig{file.Models[filepath.Base(model.FilePath)]}
+	if model.Modelfile != nil {
+		layers = append(layers, file.Models[model.ID])
+	}
+	// The derived model's own preset wins over its base's
+	for i := len(layers) - 1; i >= 0 && preset == ""; i-- {
+		preset = layers[i].Preset
+	}
+	if preset != "" {
+		p, ok := Presets(file)[preset]
+		if !ok {
+			return InferenceConfig{}, fmt.Errorf("%w: %q", ErrUnknownPreset, preset)
+		}
+		cfg.Apply(p)
+	}
+
+	cfg.Apply(layers[0].Defaults)
+	if model.Modelfile != nil {
+		cfg.Apply(model.Modelfile.Params)
+		cfg.Apply(layers[1].Defaults)
+	}
+	return cfg, nil
+}
+
+// Apply overrides the settings p sets
+func (c *InferenceConfig) Apply(p config.Params) {
+	set(&c.Temperature, p.Temperature)
+	set(&c.TopP, p.TopP)
+	set(&c.TopK, p.TopK)
+	set(&c.MinP, p.MinP)
+	set(&c.TypicalP, p.TypicalP)
+	set(&c.RepeatPenalty, p.RepeatPenalty)
+	set(&c.PresencePenalty, p.PresencePenalty)
+	set(&c.FrequencyPenalty, p.FrequencyPenalty)
+	set(&c.Mirostat, p.Mirostat)
+	set(&c.MirostatTau, p.MirostatTau)
+	set(&c.MirostatEta, p.MirostatEta)
+	set(&c.MaxTokens, p.MaxTokens)
+	set(&c.Seed, p.Seed)
+	set(&c.Threads, p.Threads)
+	set(&c.ContextSize, p.ContextSize)
+	set(&c.BatchSize, p.BatchSize)
+	if p.MLock != nil {
+		c.MLock = p.MLock
+	}
+	if p.NoMMap != nil {
+		c.NoMMap = p.NoMMap
+	}
+
+	if p.LogitBias != nil {
+		c.LogitBias = p.LogitBias
+	}
+	if p.Stop != nil {
+		c.Stop = p.Stop
+	}
+	if p.SystemPrompt != "" {
+		c.SystemPrompt = p.SystemPrompt
+	}
+	if p.Template != "" {
+		c.Template = p.Template
+	}
+}
+
+func set[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func ref[T any](v T) *T {
+	return &v
+}
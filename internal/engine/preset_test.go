@@ -0,0 +1,93 @@
+package engine_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func gguf(path string) models.ModelInfo {
+	return models.ModelInfo{ID: filepath.Base(path), FilePath: path}
+}
+
+func TestResolveConfig(t *testing.T) {
+	file := &config.Config{
+		Presets: map[string]config.Params{
+			"code": {Temperature: ptr(0.1)},
+			"mine": {TopK: ptr(7), Stop: []string{"END"}},
+		},
+		Models: map[string]config.ModelConfig{
+			"tiny.gguf":  {Preset: "mine", Defaults: config.Params{TopK: ptr(9), Threads: ptr(2)}},
+			"other.gguf": {Preset: "missing"},
+			"terse":      {Defaults: config.Params{MaxTokens: ptr(64)}},
+		},
+	}
+	def := engine.DefaultConfig()
+
+	derived := gguf("/m/tiny.gguf")
+	derived.ID = "terse"
+	derived.Modelfile = &models.Modelfile{From: "tiny.gguf", Params: config.Params{TopK: ptr(3), MaxTokens: ptr(32), SystemPrompt: "be terse"}}
+
+	tests := []struct {
+		name   string
+		model  models.ModelInfo
+		preset string
+		check  func(engine.InferenceConfig) bool
+	}{
+		{"no settings", gguf("/m/plain.gguf"), "", func(c engine.InferenceConfig) bool {
+			return c.Temperature == def.Temperature && c.TopK == def.TopK && c.ModelPath == "/m/plain.gguf"
+		}},
+		{"built-in preset", gguf("/m/plain.gguf"), "precise", func(c engine.InferenceConfig) bool {
+			return c.Temperature == 0.2 && c.TopK == 20 && c.MaxTokens == def.MaxTokens
+		}},
+		{"config file replaces a built-in preset", gguf("/m/plain.gguf"), "code", func(c engine.InferenceConfig) bool {
+			return c.Temperature == 0.1 && c.RepeatPenalty == def.RepeatPenalty
+		}},
+		{"model preset and defaults", gguf("/m/tiny.gguf"), "", func(c engine.InferenceConfig) bool {
+			return c.TopK == 9 && c.Threads == 2 && len(c.Stop) == 1 && c.Stop[0] == "END"
+		}},
+		{"model defaults win over the requested preset", gguf("/m/tiny.gguf"), "precise", func(c engine.InferenceConfig) bool {
+			return c.TopK == 9 && c.Temperature == 0.2 && len(c.Stop) == 0
+		}},
+		{"Modelfile over its base, own defaults over the Modelfile", derived, "", func(c engine.InferenceConfig) bool {
+			return c.TopK == 3 && c.MaxTokens == 64 && c.Threads == 2 && c.SystemPrompt == "be terse" && c.Stop[0] == "END" && c.ModelPath == "/m/tiny.gguf"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := engine.ResolveConfig(file, tt.model, tt.preset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("config = %+v", cfg)
+			}
+		})
+	}
+
+	if _, err := engine.ResolveConfig(file, gguf("/m/plain.gguf"), "nope"); !errors.Is(err, engine.ErrUnknownPreset) {
+		t.Errorf("unknown preset: err = %v", err)
+	}
+	if _, err := engine.ResolveConfig(file, gguf("/m/other.gguf"), ""); !errors.Is(err, engine.ErrUnknownPreset) {
+		t.Errorf("model with an unknown preset: err = %v", err)
+	}
+}
+
+func TestApplyLeavesUnsetFields(t *testing.T) {
+	cfg := engine.DefaultConfig()
+	cfg.Stop = []string{"x"}
+	cfg.Apply(config.Params{Temperature: ptr(0.0), MLock: ptr(true)})
+
+	if cfg.Temperature != 0 || cfg.MLock == nil || !*cfg.MLock {
+		t.Errorf("set fields not applied: %+v", cfg)
+	}
+	if cfg.TopP != engine.DefaultConfig().TopP || len(cfg.Stop) != 1 {
+		t.Errorf("unset fields changed: %+v", cfg)
+	}
+}
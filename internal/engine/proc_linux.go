@@ -0,0 +1,17 @@
+//go:build linux
+
+package engine
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureProcess puts the child in its own process group, so terminal
+// signals meant for us don't reach it, and has the kernel kill it if we die
+func configureProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows && !linux
+
+package engine
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureProcess puts the child in its own process group, so terminal
+// signals meant for us don't reach it
+func configureProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+}
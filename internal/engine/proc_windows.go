@@ -0,0 +1,16 @@
+//go:build windows
+
+package engine
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// configureProcess hides the console window the child would otherwise open
+func configureProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow:    true,
+		CreationFlags: 0x08000000, // CREATE_NO_WINDOW
+	}
+}
@@ -0,0 +1,56 @@
+package engine
+
+import "github.com/mibrahimzia/bitnet-runner/internal/templates"
+
+// Conversation returns the full list of turns described by the config:
+// the system prompt (if set and Messages does not start with one), the
+// prior Messages and finally Prompt as the newest user turn.
+func (c InferenceConfig) Conversation() []Message {
+	msgs := make([]Message, 0, len(c.Messages)+2)
+	hasSystem := len(c.Messages) > 0 && c.Messages[0].Role == "system"
+	if c.SystemPrompt != "" && !hasSystem {
+		msgs = append(msgs, Message{Role: "system", Content: c.SystemPrompt})
+	}
+	msgs = append(msgs, c.Messages...)
+	if c.Prompt != "" {
+		msgs = append(msgs, Message{Role: "user", Content: c.Prompt})
+	}
+	return msgs
+}
+
+// templateFor picks the chat template for a request: the name given in the
+// config wins, otherwise the one detected from the model file (cached per model)
+func (e *Executor) templateFor(config InferenceConfig) (*templates.Template, error) {
+	if config.Template != "" {
+		return templates.Get(config.Template)
+	}
+
+	e.mu.Lock()
+	t, ok := e.templates[config.ModelPath]
+	e.mu.Unlock()
+	if ok {
+		return t, nil
+	}
+
+	// Detection parses the model file, so it runs without holding e.mu.
+	// Concurrent first requests may both parse; they reach the same template.
+	t = templates.ForModel(config.ModelPath)
+	e.mu.Lock()
+	e.templates[config.ModelPath] = t
+	e.mu.Unlock()
+	return t, nil
+}
+
+// buildPrompt renders the conversation with the model's chat template and
+// returns the template's end-of-turn markers to use as extra stop words
+func (e *Executor) buildPrompt(config InferenceConfig) (string, []string, error) {
+	if config.Raw {
+		return config.Prompt, nil, nil
+	}
+
+	t, err := e.templateFor(config)
+	if err != nil {
+		return "", nil, err
+	}
+	return t.Render(config.Conversation()), t.Stop, nil
+}
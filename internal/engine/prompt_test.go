@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConversation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config InferenceConfig
+		want   []Message
+	}{
+		{
+			name:   "prompt only",
+			config: InferenceConfig{Prompt: "hi"},
+			want:   []Message{{Role: "user", Content: "hi"}},
+		},
+		{
+			name: "system prompt and history",
+			config: InferenceConfig{
+				SystemPrompt: "be brief",
+				Messages:     []Message{{Role: "user", Content: "a"}, {Role: "assistant", Content: "b"}},
+				Prompt:       "c",
+			},
+			want: []Message{
+				{Role: "system", Content: "be brief"},
+				{Role: "user", Content: "a"},
+				{Role: "assistant", Content: "b"},
+				{Role: "user", Content: "c"},
+			},
+		},
+		{
+			name: "system turn in messages wins",
+			config: InferenceConfig{
+				SystemPrompt: "ignored",
+				Messages:     []Message{{Role: "system", Content: "kept"}, {Role: "user", Content: "a"}},
+			},
+			want: []Message{{Role: "system", Content: "kept"}, {Role: "user", Content: "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.Conversation(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Conversation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPrompt(t *testing.T) {
+	e := NewPoolExecutor("", PoolConfig{})
+
+	prompt, stop, err := e.buildPrompt(InferenceConfig{Prompt: "verbatim", Raw: true})
+	if err != nil || prompt != "verbatim" || stop != nil {
+		t.Errorf("raw prompt = %q, %q, %v", prompt, stop, err)
+	}
+
+	prompt, stop, err = e.buildPrompt(InferenceConfig{Prompt: "hello", Template: "chatml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, "<|im_start|>user\nhello") || len(stop) == 0 {
+		t.Errorf("chatml prompt = %q, stop %q", prompt, stop)
+	}
+
+	if _, _, err := e.buildPrompt(InferenceConfig{Prompt: "x", Template: "no-such-template"}); err == nil {
+		t.Error("unknown template accepted")
+	}
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrQueueFull is returned when a model already has QueueLimit requests waiting
+var ErrQueueFull = errors.New("too many requests waiting for the model")
+
+// Queue orderings, selectable with PoolConfig.QueueOrder
+const (
+	QueueFIFO     = "fifo"     // first come, first served
+	QueuePriority = "priority" // higher InferenceConfig.Priority first, then first come
+)
+
+// QueueStats describes the request queue of one model
+type QueueStats struct {
+	ModelPath string `json:"model_path"`
+	Slots     int    `json:"slots"`   // generations allowed at the same time
+	Running   int    `json:"running"` // generations holding a slot
+	Waiting   int    `json:"waiting"` // requests queued for a slot
+	Admitted  int64  `json:"admitted"`
+	Rejected  int64  `json:"rejected"` // turned away because the queue was full
+	AvgWaitMs int64  `json:"avg_wait_ms"`
+	MaxWaitMs int64  `json:"max_wait_ms"`
+}
+
+// modelQueue hands out a model's generation slots. Waiting tickets are kept
+// in the order they will be admitted.
+type modelQueue struct {
+	running   int
+	waiting   []*ticket
+	admitted  int64
+	rejected  int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// ticket is one request waiting for a slot
+type ticket struct {
+	id        string
+	priority  int
+	enqueued  time.Time
+	admit     chan struct{} // closed when the request gets a slot
+	notify    func(position int)
+	positions chan int // latest position not yet passed to notify
+}
+
+// enqueue waits until the request may generate on modelPath. Requests are
+// admitted in QueueOrder while fewer than Parallel hold a slot. Every
+// successful call must be paired with dequeue.
+func (e *Executor) enqueue(ctx context.Context, modelPath string, config InferenceConfig, id string) error {
+	e.mu.Lock()
+	q := e.queueLocked(modelPath)
+	if q.running < e.slots() && len(q.waiting) == 0 {
+		q.running++
+		q.recordLocked(0)
+		if r, ok := e.requests[id]; ok {
+			r.State = requestRunning
+		}
+		e.mu.Unlock()
+		return nil
+	}
+	if limit := e.pool.QueueLimit; limit > 0 && len(q.waiting) >= limit {
+		q.rejected++
+		e.mu.Unlock()
+		return fmt.Errorf("%w: %s has %d queued", ErrQueueFull, filepath.Base(modelPath), limit)
+	}
+
+	t := &ticket{
+		id:        id,
+		priority:  config.Priority,
+		enqueued:  time.Now(),
+		admit:     make(chan struct{}),
+		notify:    config.OnQueued,
+		positions: make(chan int, 1),
+	}
+	q.insertLocked(t, e.pool.QueueOrder == QueuePriority)
+	e.reportPositionsLocked(modelPath, q)
+	e.mu.Unlock()
+
+	// Positions are passed on here, one at a time, so a slow callback
+	// neither blocks the executor nor sees them out of order
+	wait := true
+	for wait {
+		select {
+		case position := <-t.positions:
+			select {
+			case <-t.admit:
+				return nil
+			default:
+				t.notify(position)
+			}
+		case <-t.admit:
+			return nil
+		case <-ctx.Done():
+			wait = false
+		}
+	}
+
+	e.mu.Lock()
+	for i, w := range q.waiting {
+		if w == t {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			e.reportPositionsLocked(modelPath, q)
+			e.dropQueueLocked(modelPath)
+			e.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	e.mu.Unlock()
+
+	// Admitted while giving up: hand the slot on
+	e.dequeue(modelPath)
+	return ctx.Err()
+}
+
+// dequeue frees a slot and admits the next waiting request
+func (e *Executor) dequeue(modelPath string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	q := e.queueLocked(modelPath)
+	q.running--
+	if len(q.waiting) > 0 && q.running < e.slots() {
+		next := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.running++
+		q.recordLocked(time.Since(next.enqueued))
+		if r, ok := e.requests[next.id]; ok {
+			r.State = requestRunning
+			r.Position = 0
+			r.WaitMs = time.Since(next.enqueued).Milliseconds()
+		}
+		close(next.admit)
+		e.reportPositionsLocked(modelPath, q)
+	}
+	e.dropQueueLocked(modelPath)
+}
+
+// Queues reports the request queue of every model that is loaded or has
+// requests running or waiting
+func (e *Executor) Queues() []QueueStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]QueueStats, 0, len(e.queues))
+	for path, q := range e.queues {
+		s := QueueStats{
+			ModelPath: path,
+			Slots:     e.slots(),
+			Running:   q.running,
+			Waiting:   len(q.waiting),
+			Admitted:  q.admitted,
+			Rejected:  q.rejected,
+			MaxWaitMs: q.maxWait.Milliseconds(),
+		}
+		if q.admitted > 0 {
+			s.AvgWaitMs = (q.totalWait / time.Duration(q.admitted)).Milliseconds()
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ModelPath < list[j].ModelPath })
+	return list
+}
+
+// slots is how many generations run at once per model
+func (e *Executor) slots() int {
+	return max(e.pool.Parallel, 1)
+}
+
+func (e *Executor) queueLocked(modelPath string) *modelQueue {
+	q, ok := e.queues[modelPath]
+	if !ok {
+		q = &modelQueue{}
+		e.queues[modelPath] = q
+	}
+	return q
+}
+
+// dropQueueLocked forgets the queue of a model that is no longer loaded
+// once no request runs on it or waits for it
+func (e *Executor) dropQueueLocked(modelPath string) {
+	q, ok := e.queues[modelPath]
+	if !ok || q.running > 0 || len(q.waiting) > 0 {
+		return
+	}
+	if _, loaded := e.instances[modelPath]; !loaded {
+		delete(e.queues, modelPath)
+	}
+}
+
+// reportPositionsLocked updates the registry with the 1-based queue
+// position of every waiting request whose position changed, and tells
+// event subscribers and the request itself. Sending while e.mu is held
+// keeps the updates in order; a request that has not taken its previous
+// position yet only gets the latest one.
+func (e *Executor) reportPositionsLocked(modelPath string, q *modelQueue) {
+	for i, t := range q.waiting {
+		position := i + 1
+		if r, ok := e.requests[t.id]; ok {
+			if r.Position == position {
+				continue
+			}
+			r.State = requestQueued
+			r.Position = position
+		}
+		e.emit(Event{Type: EventQueued, Model: modelPath, Request: t.id, Position: position})
+		if t.notify != nil {
+			select {
+			case <-t.positions:
+			default:
+			}
+			t.positions <- position
+		}
+	}
+}
+
+// insertLocked queues a ticket behind all others, or with byPriority behind
+// those of equal or higher priority
+func (q *modelQueue) insertLocked(t *ticket, byPriority bool) {
+	i := len(q.waiting)
+	if byPriority {
+		i = sort.Search(len(q.waiting), func(i int) bool { return q.waiting[i].priority < t.priority })
+	}
+	q.waiting = append(q.waiting, nil)
+	copy(q.waiting[i+1:], q.waiting[i:])
+	q.waiting[i] = t
+}
+
+func (q *modelQueue) recordLocked(wait time.Duration) {
+	q.admitted++
+	q.totalWait += wait
+	q.maxWait = max(q.maxWait, wait)
+}
@@ -0,0 +1,252 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine/enginetest"
+)
+
+// startAsync starts a generation in the background, since a queued
+// StartInference only returns once the request has a slot
+func startAsync(e *engine.Executor, cfg engine.InferenceConfig) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		tokens, err := e.StartInference(context.Background(), cfg)
+		if err == nil {
+			for range tokens {
+			}
+		}
+		done <- err
+	}()
+	return done
+}
+
+// waitQueued waits until n requests are waiting on the first model
+func waitQueued(t *testing.T, e *engine.Executor, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if q := e.Queues(); len(q) == 1 && q[0].Waiting == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("queue never had %d waiting: %+v", n, e.Queues())
+}
+
+func TestQueueWaitsForSlot(t *testing.T) {
+	e, model, _ := attachedPool(t, enginetest.Script{Tokens: []string{"a", "b", "c", "d"}, TokenDelay: 20 * time.Millisecond},
+		engine.PoolConfig{MaxModels: 1, Parallel: 1})
+	events, unsubscribe := e.Subscribe()
+	defer unsubscribe()
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	cfg.RequestID = "first"
+	first, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var positions []int
+	cfg.RequestID = "second"
+	cfg.OnQueued = func(position int) {
+		mu.Lock()
+		positions = append(positions, position)
+		mu.Unlock()
+	}
+	second := startAsync(e, cfg)
+	waitQueued(t, e, 1)
+
+	if ev := waitEvent(t, events, engine.EventQueued); ev.Request != "second" || ev.Position != 1 {
+		t.Errorf("queued event = %+v", ev)
+	}
+	if reqs := e.Requests(); len(reqs) != 2 || reqs[1].State != "queued" || reqs[1].Position != 1 {
+		t.Errorf("Requests() = %+v", reqs)
+	}
+
+	if got, _ := collect(t, first); got != "abcd" {
+		t.Errorf("first output = %q", got)
+	}
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if !reflect.DeepEqual(positions, []int{1}) {
+		t.Errorf("OnQueued positions = %v, want [1]", positions)
+	}
+	mu.Unlock()
+
+	q := e.Queues()[0]
+	if q.Slots != 1 || q.Running != 0 || q.Waiting != 0 || q.Admitted != 2 || q.MaxWaitMs == 0 {
+		t.Errorf("queue stats = %+v", q)
+	}
+}
+
+func TestQueueLimit(t *testing.T) {
+	e, model, _ := attachedPool(t, slowTokens, engine.PoolConfig{MaxModels: 1, Parallel: 1, QueueLimit: 1})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	running, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiting := startAsync(e, cfg)
+	waitQueued(t, e, 1)
+
+	if _, err := e.StartInference(context.Background(), cfg); !errors.Is(err, engine.ErrQueueFull) {
+		t.Errorf("request over the limit = %v, want ErrQueueFull", err)
+	}
+	if q := e.Queues()[0]; q.Rejected != 1 {
+		t.Errorf("queue stats = %+v", q)
+	}
+
+	e.Stop()
+	collect(t, running)
+	<-waiting
+}
+
+func TestQueueCancelWhileWaiting(t *testing.T) {
+	e, model, _ := attachedPool(t, slowTokens, engine.PoolConfig{MaxModels: 1, Parallel: 1})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	running, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.RequestID = "waiting"
+	waiting := startAsync(e, cfg)
+	waitQueued(t, e, 1)
+
+	if err := e.Cancel("waiting"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-waiting; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled queued request = %v", err)
+	}
+	if q := e.Queues()[0]; q.Waiting != 0 || q.Running != 1 {
+		t.Errorf("queue stats = %+v", q)
+	}
+
+	e.Stop()
+	collect(t, running)
+}
+
+func TestQueuePriority(t *testing.T) {
+	e, model, _ := attachedPool(t, slowTokens, engine.PoolConfig{MaxModels: 1, Parallel: 1, QueueOrder: engine.QueuePriority})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	running, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, id := range []string{"low", "high", "also-low"} {
+		cfg.RequestID = id
+		cfg.Priority = map[string]int{"high": 10}[id]
+		startAsync(e, cfg)
+		waitQueued(t, e, i+1)
+	}
+
+	byPosition := make([]string, 3)
+	for _, r := range e.Requests() {
+		if r.Position > 0 {
+			byPosition[r.Position-1] = r.ID
+		}
+	}
+	if want := []string{"high", "low", "also-low"}; !reflect.DeepEqual(byPosition, want) {
+		t.Errorf("queue order = %v, want %v", byPosition, want)
+	}
+
+	e.Stop()
+	collect(t, running)
+}
+
+func TestQueuePositionsOnlyDecrease(t *testing.T) {
+	e, model, _ := attachedPool(t, enginetest.Script{Tokens: []string{"a", "b"}, TokenDelay: 10 * time.Millisecond},
+		engine.PoolConfig{MaxModels: 1, Parallel: 1})
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = model
+	cfg.Prompt = "x"
+	first, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 4
+	var mu sync.Mutex
+	positions := make([][]int, n)
+	done := make([]<-chan error, n)
+	for i := range n {
+		cfg.OnQueued = func(position int) {
+			time.Sleep(time.Millisecond) // a slow client
+			mu.Lock()
+			positions[i] = append(positions[i], position)
+			mu.Unlock()
+		}
+		done[i] = startAsync(e, cfg)
+		waitQueued(t, e, i+1)
+	}
+
+	collect(t, first)
+	for _, d := range done {
+		if err := <-d; err != nil {
+			t.Fatal(err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, p := range positions {
+		if len(p) == 0 {
+			t.Errorf("request %d: no positions", i)
+		}
+		for j := 1; j < len(p); j++ {
+			if p[j] >= p[j-1] {
+				t.Errorf("request %d: positions %v out of order", i, p)
+				break
+			}
+		}
+	}
+}
+
+func TestQueueDroppedWithModel(t *testing.T) {
+	e := spawned(t, enginetest.Script{Tokens: []string{"a"}}, engine.PoolConfig{MaxModels: 1})
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.gguf"), filepath.Join(dir, "b.gguf")
+
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = a
+	cfg.Prompt = "x"
+	stream, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(t, stream)
+	if q := e.Queues(); len(q) != 1 || q[0].ModelPath != a || q[0].Admitted != 1 {
+		t.Fatalf("queues while a is loaded = %+v", q)
+	}
+
+	// Loading b unloads a, which has nothing running or waiting
+	if err := e.LoadModel(b); err != nil {
+		t.Fatal(err)
+	}
+	if q := e.Queues(); len(q) != 0 {
+		t.Errorf("queues after a was unloaded = %+v", q)
+	}
+}
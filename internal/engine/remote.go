@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mibrahimzia/bitnet-runner/pkg/api"
+)
+
+// remote forwards requests to another bitnet-runner instance. The remote
+// side keeps the model loaded and applies its own engine settings.
+type remote struct {
+	baseURL string
+	model   string // model ID on the remote
+	local   string // model path or ID used locally, for errors
+	client  *http.Client
+}
+
+func newRemote(baseURL, model, modelPath string) *remote {
+	if model == "" {
+		model = filepath.Base(modelPath)
+	}
+	return &remote{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		local:   modelPath,
+		client:  &http.Client{},
+	}
+}
+
+// Load asks the remote to load the model, like an empty Ollama generate request
+func (r *remote) Load(ctx context.Context) error {
+	if r.baseURL == "" {
+		return &LoadError{Kind: ErrModelLoadFailed, Model: r.local, Reason: "no url configured for the remote backend"}
+	}
+	resp, err := r.post(ctx, "/api/generate", api.OllamaGenerateRequest{Model: r.model})
+	if err != nil {
+		return &LoadError{Kind: ErrModelLoadFailed, Model: r.local, Reason: err.Error()}
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Unload leaves the model loaded on the remote, which manages its own pool
+func (r *remote) Unload() error {
+	return nil
+}
+
+// Generate sends a conversation to the remote's /api/chat, so the remote
+// renders it with its own chat template, and an already rendered prompt to
+// /api/generate as raw text
+func (r *remote) Generate(ctx context.Context, req GenerateRequest) (<-chan StreamEvent, error) {
+	stream := true
+	options := &api.OllamaOptions{
+		Temperature:      &req.Temperature,
+		TopP:             &req.TopP,
+		TopK:             &req.TopK,
+		NumPredict:       &req.MaxTokens,
+		RepeatPenalty:    &req.RepeatPenalty,
+		Seed:             &req.Seed,
+		Stop:             req.Stop,
+		MinP:             nonZero(req.MinP),
+		TypicalP:         nonZero(req.TypicalP),
+		PresencePenalty:  nonZero(req.PresencePenalty),
+		FrequencyPenalty: nonZero(req.FrequencyPenalty),
+		Mirostat:         nonZero(req.Mirostat),
+		MirostatTau:      nonZero(req.MirostatTau),
+		MirostatEta:      nonZero(req.MirostatEta),
+	}
+	// Load-time settings and logit biases stay with the remote's own setup
+	var resp *http.Response
+	var err error
+	if req.Messages != nil {
+		messages := make([]api.OllamaMessage, len(req.Messages))
+		for i, m := range req.Messages {
+			messages[i] = api.OllamaMessage{Role: m.Role, Content: m.Content}
+		}
+		resp, err = r.post(ctx, "/api/chat", api.OllamaChatRequest{
+			Model:    r.model,
+			Messages: messages,
+			Stream:   &stream,
+			Options:  options,
+		})
+	} else {
+		resp, err = r.post(ctx, "/api/generate", api.OllamaGenerateRequest{
+			Model:   r.model,
+			Prompt:  req.Prompt,
+			Raw:     true, // the prompt is already rendered with the chat template
+			Stream:  &stream,
+			Options: options,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		defer resp.Body.Close()
+
+		send := func(ev StreamEvent) bool {
+			select {
+			case out <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var chunk remoteChunk
+			if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+				send(errorEvent(fmt.Errorf("remote %s: invalid chunk: %w", r.baseURL, err)))
+				return
+			}
+			if chunk.Error != "" {
+				send(errorEvent(fmt.Errorf("remote %s: %s", r.baseURL, chunk.Error)))
+				return
+			}
+			text := chunk.Response
+			if chunk.Message != nil {
+				text = chunk.Message.Content
+			}
+			if text != "" && !send(tokenEvent(text)) {
+				return
+			}
+			if chunk.Done {
+				send(doneEvent(remoteCompletion(chunk.OllamaGenerateResponse)))
+				return
+			}
+		}
+		if ctx.Err() == nil {
+			send(errorEvent(ErrStreamCut))
+		}
+	}()
+	return out, nil
+}
+
+// remoteChunk is an NDJSON line of /api/generate or /api/chat, which carry
+// the text in response and message respectively
+type remoteChunk struct {
+	api.OllamaGenerateResponse
+	Message *api.OllamaMessage `json:"message,omitempty"`
+}
+
+// nonZero returns a pointer to v, or nil to leave the setting to the remote
+func nonZero[T int | float64](v T) *T {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// remoteCompletion reads the counts and durations of a final Ollama chunk.
+// Ollama only tells a length limit apart from other stops.
+func remoteCompletion(chunk api.OllamaGenerateResponse) Completion {
+	c := Completion{
+		StopReason:      StopEOS,
+		TokensEvaluated: chunk.PromptEvalCount,
+		TokensPredicted: chunk.EvalCount,
+		Timings: Timings{
+			PromptMs:    float64(chunk.PromptEvalDuration) / 1e6,
+			PredictedMs: float64(chunk.EvalDuration) / 1e6,
+		},
+	}
+	if chunk.DoneReason == "length" {
+		c.StopReason = StopMaxTokens
+	}
+	if ms := c.Timings.PromptMs; ms > 0 {
+		c.Timings.PromptPerSecond = float64(c.TokensEvaluated) * 1000 / ms
+	}
+	if ms := c.Timings.PredictedMs; ms > 0 {
+		c.Timings.PredictedPerSecond = float64(c.TokensPredicted) * 1000 / ms
+	}
+	return c
+}
+
+func (r *remote) Tokenize(ctx context.Context, text string) ([]int, error) {
+	resp, err := r.post(ctx, "/api/v1/tokenize", api.TokenizeRequest{Model: r.model, Content: text})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data api.TokenizeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid tokenize response: %w", err)
+	}
+	return data.Tokens, nil
+}
+
+func (r *remote) Embed(ctx context.Context, text string) ([]float32, error) {
+	resp, err := r.post(ctx, "/api/v1/embed", api.EmbedRequest{Model: r.model, Content: text})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var data api.EmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid embed response: %w", err)
+	}
+	return data.Embedding, nil
+}
+
+func (r *remote) Health(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", r.baseURL+"/api/v1/engine/status", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote %s is not responding: %w", r.baseURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("remote %s: %s", r.baseURL, resp.Status)
+	}
+	return nil
+}
+
+// post sends a JSON request to the remote. Error statuses are turned into
+// errors carrying the remote's message.
+func (r *remote) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, "POST", r.baseURL+path, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connection to %s failed: %w", r.baseURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var e api.ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return nil, fmt.Errorf("remote %s: %s", r.baseURL, e.Error)
+		}
+		return nil, fmt.Errorf("remote %s: %s", r.baseURL, resp.Status)
+	}
+	return resp, nil
+}
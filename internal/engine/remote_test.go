@@ -0,0 +1,96 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/pkg/api"
+)
+
+// remoteStub stands in for another bitnet-runner and records the bodies of
+// the generation requests it gets, by path
+type remoteStub struct {
+	mu       sync.Mutex
+	chat     []api.OllamaChatRequest
+	generate []api.OllamaGenerateRequest
+}
+
+func (r *remoteStub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enc := json.NewEncoder(w)
+	switch req.URL.Path {
+	case "/api/chat":
+		var body api.OllamaChatRequest
+		json.NewDecoder(req.Body).Decode(&body)
+		r.chat = append(r.chat, body)
+		enc.Encode(api.OllamaChatResponse{Message: &api.OllamaMessage{Role: "assistant", Content: "Hi"}})
+		enc.Encode(api.OllamaChatResponse{Done: true, DoneReason: "stop", EvalCount: 1})
+	case "/api/generate":
+		var body api.OllamaGenerateRequest
+		json.NewDecoder(req.Body).Decode(&body)
+		if body.Prompt != "" {
+			r.generate = append(r.generate, body)
+		}
+		enc.Encode(api.OllamaGenerateResponse{Response: "Hi"})
+		enc.Encode(api.OllamaGenerateResponse{Done: true, DoneReason: "length", EvalCount: 1})
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestRemoteUsesItsOwnTemplate(t *testing.T) {
+	stub := &remoteStub{}
+	srv := httptest.NewServer(stub)
+	defer srv.Close()
+	e := engine.NewPoolExecutor("", engine.PoolConfig{
+		MaxModels: 1,
+		Models:    map[string]config.ModelConfig{"llama3": {Backend: engine.BackendRemote, URL: srv.URL}},
+	})
+	t.Cleanup(func() { e.Shutdown() })
+
+	// The conversation goes to /api/chat for the remote to render
+	cfg := engine.DefaultConfig()
+	cfg.ModelPath = "llama3"
+	cfg.SystemPrompt = "Be brief."
+	cfg.Messages = []engine.Message{{Role: "user", Content: "hello"}, {Role: "assistant", Content: "hey"}}
+	cfg.Prompt = "how are you?"
+	stream, err := e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, done := collect(t, stream); text != "Hi" || done.Completion.StopReason != engine.StopEOS {
+		t.Errorf("chat = %q, %+v", text, done.Completion)
+	}
+	if len(stub.chat) != 1 {
+		t.Fatalf("/api/chat requests = %+v", stub.chat)
+	}
+	want := []api.OllamaMessage{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "hello"},
+		{Role: "assistant", Content: "hey"},
+		{Role: "user", Content: "how are you?"},
+	}
+	if got := stub.chat[0].Messages; len(got) != len(want) || got[0] != want[0] || got[3] != want[3] {
+		t.Errorf("messages = %+v, want %+v", got, want)
+	}
+
+	// A template named by the request is applied here, and the result sent raw
+	cfg.Template = "chatml"
+	stream, err = e.StartInference(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, done := collect(t, stream); text != "Hi" || done.Completion.StopReason != engine.StopMaxTokens {
+		t.Errorf("generate = %q, %+v", text, done.Completion)
+	}
+	if len(stub.generate) != 1 || !stub.generate[0].Raw || stub.generate[0].Prompt == "" {
+		t.Errorf("/api/generate requests = %+v", stub.generate)
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrRequestNotFound is returned by Cancel for an unknown or finished request
+var ErrRequestNotFound = errors.New("no such request")
+
+// Request states
+const (
+	requestQueued  = "queued"  // waiting for a slot on the model
+	requestRunning = "running" // generating
+)
+
+// ActiveRequest describes a generation in flight
+type ActiveRequest struct {
+	ID        string    `json:"id"`
+	ModelPath string    `json:"model_path"`
+	Started   time.Time `json:"started"`
+	State     string    `json:"state"`              // queued or running
+	Position  int       `json:"position,omitempty"` // place in the queue, starting at 1
+	WaitMs    int64     `json:"wait_ms"`            // time spent queued before running
+}
+
+// inflight is a registered generation and the function that cancels it
+type inflight struct {
+	ActiveRequest
+	cancel context.CancelFunc
+}
+
+// NewRequestID returns a random request ID such as "req-3f2a..."
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "req-" + hex.EncodeToString(b)
+}
+
+// Requests lists the generations in flight, oldest first
+func (e *Executor) Requests() []ActiveRequest {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	list := make([]ActiveRequest, 0, len(e.requests))
+	for _, r := range e.requests {
+		list = append(list, r.ActiveRequest)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+// Cancel stops one generation. Its stream is closed shortly after; the model
+// stays loaded.
+func (e *Executor) Cancel(id string) error {
+	e.mu.Lock()
+	r, ok := e.requests[id]
+	e.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRequestNotFound, id)
+	}
+	r.cancel()
+	return nil
+}
+
+// register records a new generation under config.RequestID (or a fresh ID)
+// and returns the context that Cancel, Stop and the caller's ctx all end
+func (e *Executor) register(ctx context.Context, config InferenceConfig) (context.Context, string, error) {
+	id := config.RequestID
+	if id == "" {
+		id = NewRequestID()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &inflight{
+		ActiveRequest: ActiveRequest{ID: id, ModelPath: config.ModelPath, Started: time.Now(), State: requestQueued},
+		cancel:        cancel,
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.requests[id]; exists {
+		cancel()
+		return nil, "", fmt.Errorf("request %s is already running", id)
+	}
+	e.requests[id] = r
+	return ctx, id, nil
+}
+
+// unregister removes a finished generation and releases its context
+func (e *Executor) unregister(id string) {
+	e.mu.Lock()
+	r, ok := e.requests[id]
+	delete(e.requests, id)
+	e.mu.Unlock()
+	if ok {
+		r.cancel()
+	}
+}
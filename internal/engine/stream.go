@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// StreamEventType identifies the items of a generation stream
+type StreamEventType string
+
+const (
+	StreamToken StreamEventType = "token" // a piece of generated text
+	StreamError StreamEventType = "error" // generation failed part way
+	StreamDone  StreamEventType = "done"  // generation finished
+)
+
+// Stop reasons reported with the done event
+const (
+	StopEOS       = "eos"        // the model ended its reply
+	StopMaxTokens = "max_tokens" // MaxTokens were generated
+	StopWord      = "stop_word"  // a stop sequence was generated
+	StopCancelled = "cancelled"  // the request was cancelled
+)
+
+// ErrStreamCut is reported when the engine stops streaming without saying
+// the generation finished, for example because the connection broke
+var ErrStreamCut = errors.New("engine closed the stream before the generation finished")
+
+// finalEventWait is how long a cancelled stream waits for its reader to take
+// the done event before giving up on a reader that has gone away
+const finalEventWait = time.Second
+
+// StreamEvent is one item of a generation stream. StartInference streams
+// end with exactly one error or done event; tokens only come before it.
+type StreamEvent struct {
+	Type       StreamEventType `json:"type"`
+	Token      string          `json:"token,omitempty"`      // StreamToken
+	Err        error           `json:"-"`                    // StreamError
+	Completion *Completion     `json:"completion,omitempty"` // StreamDone
+}
+
+// Completion describes a finished generation
+type Completion struct {
+	StopReason      string  `json:"stop_reason"`
+	StopWord        string  `json:"stop_word,omitempty"` // the stop sequence that ended it
+	TokensEvaluated int     `json:"tokens_evaluated"`    // prompt tokens processed
+	TokensPredicted int     `json:"tokens_predicted"`    // tokens generated
+	Timings         Timings `json:"timings"`             // zero unless the backend measures them
+}
+
+// Timings are the speeds llama-server reports for a generation
+type Timings struct {
+	PromptMs           float64 `json:"prompt_ms"`
+	PromptPerSecond    float64 `json:"prompt_per_second"`
+	PredictedMs        float64 `json:"predicted_ms"`
+	PredictedPerSecond float64 `json:"predicted_per_second"`
+}
+
+func tokenEvent(text string) StreamEvent {
+	return StreamEvent{Type: StreamToken, Token: text}
+}
+
+func errorEvent(err error) StreamEvent {
+	return StreamEvent{Type: StreamError, Err: err}
+}
+
+func doneEvent(c Completion) StreamEvent {
+	return StreamEvent{Type: StreamDone, Completion: &c}
+}
+
+// forward copies tokens from a backend stream to out until the backend is
+// done or ctx is cancelled, and returns the event that ends the stream.
+// Backends may close their stream without a final event once ctx is
+// cancelled; a stream that ends early otherwise is reported as cut.
+func forward(ctx context.Context, events <-chan StreamEvent, out chan<- StreamEvent) StreamEvent {
+	count := 0
+	var final StreamEvent
+	for ev := range events {
+		if ev.Type != StreamToken {
+			final = ev
+			continue
+		}
+		select {
+		case out <- ev:
+			count++
+		case <-ctx.Done():
+			// The caller may have stopped reading; let the backend wind down
+			for range events {
+			}
+		}
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		c := Completion{StopReason: StopCancelled, TokensPredicted: count}
+		if final.Completion != nil {
+			c.TokensEvaluated = final.Completion.TokensEvaluated
+			c.Timings = final.Completion.Timings
+		}
+		return doneEvent(c)
+	case final.Type == "":
+		return errorEvent(ErrStreamCut)
+	case final.Type == StreamDone && final.Completion.TokensPredicted == 0:
+		// Backends that cannot count tokens report the chunks streamed
+		final.Completion.TokensPredicted = count
+	}
+	return final
+}
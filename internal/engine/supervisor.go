@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Restart policy for crashed engines
+const (
+	maxRestarts    = 5
+	restartBackoff = time.Second      // delay before the first restart, doubled each time
+	maxBackoff     = 30 * time.Second // cap on the restart delay
+	stableAfter    = 5 * time.Minute  // uptime after which the restart count resets
+	crashHistory   = 20               // crashes remembered by the executor
+)
+
+// Crash records an unexpected exit of a child server
+type Crash struct {
+	Model  string    `json:"model"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+	Tail   []string  `json:"tail,omitempty"` // last lines of engine output
+}
+
+// Crashes returns the most recent engine crashes, oldest first
+func (e *Executor) Crashes() []Crash {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Crash(nil), e.crashes...)
+}
+
+// load loads the model of inst and publishes the outcome. It runs once per
+// instance, started by the request that registered inst in the pool or by
+// the supervisor for a restart.
+func (e *Executor) load(inst *instance) {
+	if inst.restarts == 0 {
+		e.emit(Event{Type: EventLoading, Model: inst.modelPath})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout(e.pool.LoadTimeout, inst.size))
+	err := inst.backend.Load(ctx)
+	cancel()
+
+	e.mu.Lock()
+	inst.loadErr = err
+	if err == nil {
+		inst.state = stateReady
+		inst.readyAt = time.Now()
+	} else if e.instances[inst.modelPath] == inst {
+		delete(e.instances, inst.modelPath)
+		e.dropQueueLocked(inst.modelPath)
+	}
+	e.notifyIdleLocked()
+	e.mu.Unlock()
+	close(inst.ready)
+
+	if err != nil {
+		inst.stop()
+		e.emit(Event{Type: EventFailed, Model: inst.modelPath, Reason: err.Error(), Attempt: inst.restarts})
+		return
+	}
+	e.emit(Event{Type: EventReady, Model: inst.modelPath})
+	go e.supervise(inst)
+}
+
+// supervise waits for the backend's child process to exit. An exit that was not
+// requested is recorded as a crash and the model is restarted with
+// exponential backoff; requests arriving meanwhile wait for the restart.
+func (e *Executor) supervise(inst *instance) {
+	proc, ok := inst.backend.(processBackend)
+	if !ok || proc.Exited() == nil {
+		return
+	}
+	<-proc.Exited()
+	if inst.unloaded.Load() {
+		return
+	}
+
+	crash := Crash{Model: inst.modelPath, Time: time.Now()}
+	crash.Reason, crash.Tail = proc.ExitReason()
+
+	e.mu.Lock()
+	e.crashes = append(e.crashes, crash)
+	if len(e.crashes) > crashHistory {
+		e.crashes = e.crashes[len(e.crashes)-crashHistory:]
+	}
+	if e.instances[inst.modelPath] != inst {
+		e.mu.Unlock()
+		return
+	}
+	next := e.newInstance(inst.modelPath, inst.load)
+	next.state = stateRestarting
+	next.restarts = inst.restarts + 1
+	if time.Since(inst.readyAt) > stableAfter {
+		next.restarts = 1
+	}
+	next.lastCrash = &crash
+	e.instances[inst.modelPath] = next
+	e.mu.Unlock()
+
+	e.emit(Event{Type: EventCrashed, Model: inst.modelPath, Reason: crash.Reason})
+
+	if next.restarts > maxRestarts {
+		e.mu.Lock()
+		next.loadErr = &LoadError{
+			Kind:   ErrEngineExited,
+			Model:  next.modelPath,
+			Reason: fmt.Sprintf("crashed %d times, giving up: %s", maxRestarts, crash.Reason),
+			Tail:   crash.Tail,
+		}
+		if e.instances[next.modelPath] == next {
+			delete(e.instances, next.modelPath)
+			e.dropQueueLocked(next.modelPath)
+		}
+		e.notifyIdleLocked()
+		e.mu.Unlock()
+		close(next.ready)
+		e.emit(Event{Type: EventFailed, Model: next.modelPath, Reason: next.loadErr.Error(), Attempt: next.restarts})
+		return
+	}
+
+	delay := min(restartBackoff<<(next.restarts-1), maxBackoff)
+	e.emit(Event{
+		Type:    EventRestarting,
+		Model:   next.modelPath,
+		Reason:  fmt.Sprintf("restarting in %s", delay),
+		Attempt: next.restarts,
+	})
+	time.Sleep(delay)
+	e.load(next)
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ModelDetails is the full description of a model file, as shown by `bitnet show`
+type ModelDetails struct {
+	ModelInfo
+	GGUFVersion  uint32         `json:"gguf_version"`
+	Layout       LayoutInfo     `json:"layout"`
+	Rope         RopeInfo       `json:"rope"`
+	Tokenizer    TokenizerInfo  `json:"tokenizer"`
+	ChatTemplate string         `json:"chat_template"`
+	TensorTypes  map[string]int `json:"tensor_types"` // number of tensors per quantization type
+	Metadata     map[string]any `json:"metadata"`
+	Tensors      []GGUFTensor   `json:"tensors"`
+}
+
+// LayoutInfo describes the transformer dimensions
+type LayoutInfo struct {
+	EmbeddingLength uint64 `json:"embedding_length"`
+	BlockCount      uint64 `json:"block_count"`
+	HeadCount       uint64 `json:"head_count"`
+	HeadCountKV     uint64 `json:"head_count_kv"`
+	FeedForward     uint64 `json:"feed_forward_length"`
+}
+
+// RopeInfo holds the rotary position embedding settings
+type RopeInfo struct {
+	DimensionCount uint64  `json:"dimension_count"`
+	FreqBase       float64 `json:"freq_base"`
+	ScalingType    string  `json:"scaling_type"`
+	ScalingFactor  float64 `json:"scaling_factor"`
+}
+
+// TokenizerInfo describes the embedded tokenizer
+type TokenizerInfo struct {
+	Model     string `json:"model"` // e.g. "gpt2", "llama"
+	VocabSize uint64 `json:"vocab_size"`
+	BOSToken  uint64 `json:"bos_token_id"`
+	EOSToken  uint64 `json:"eos_token_id"`
+}
+
+// Resolve finds a model by ID or filename, or accepts a path to a GGUF file
+// outside the models directory
+func (m *Manager) Resolve(id string) (ModelInfo, error) {
+	info, err := m.Find(id)
+	if err == nil {
+		return info, nil
+	}
+
+	stat, statErr := os.Stat(id)
+	if statErr != nil || stat.IsDir() {
+		return ModelInfo{}, err
+	}
+	abs, absErr := filepath.Abs(id)
+	if absErr != nil {
+		abs = id
+	}
+	return inspectModel(abs, stat)
+}
+
+// Show returns the full metadata and tensor table of a model
+func (m *Manager) Show(id string) (*ModelDetails, error) {
+	info, err := m.Resolve(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(info.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	header, err := readHeader(info.FilePath, stat)
+	if err != nil {
+		return nil, err
+	}
+
+	arch := header.Architecture()
+	details := &ModelDetails{
+		ModelInfo:   info,
+		GGUFVersion: header.Version,
+		Layout: LayoutInfo{
+			EmbeddingLength: header.Uint(arch + ".embedding_length"),
+			BlockCount:      header.Uint(arch + ".block_count"),
+			HeadCount:       header.Uint(arch + ".attention.head_count"),
+			HeadCountKV:     header.Uint(arch + ".attention.head_count_kv"),
+			FeedForward:     header.Uint(arch + ".feed_forward_length"),
+		},
+		Rope: RopeInfo{
+			DimensionCount: header.Uint(arch + ".rope.dimension_count"),
+			FreqBase:       header.Float(arch + ".rope.freq_base"),
+			ScalingType:    header.String(arch + ".rope.scaling.type"),
+			ScalingFactor:  header.Float(arch + ".rope.scaling.factor"),
+		},
+		Tokenizer: TokenizerInfo{
+			Model:     header.String("tokenizer.ggml.model"),
+			VocabSize: header.VocabSize(),
+			BOSToken:  header.Uint("tokenizer.ggml.bos_token_id"),
+			EOSToken:  header.Uint("tokenizer.ggml.eos_token_id"),
+		},
+		ChatTemplate: header.String("tokenizer.chat_template"),
+		TensorTypes:  make(map[string]int),
+		Metadata:     header.Metadata,
+		Tensors:      header.Tensors,
+	}
+	for _, t := range header.Tensors {
+		details.TensorTypes[t.Type]++
+	}
+	return details, nil
+}
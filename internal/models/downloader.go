@@ -1,117 +1,338 @@
 package models
 
 import (
-    "fmt"
-    "io"
-    "net/http"
-    "os"
-    "path/filepath"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrChecksumMismatch is returned when a downloaded file does not have the expected SHA-256
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrDownloadInProgress is returned when the same file is already being downloaded
+var ErrDownloadInProgress = errors.New("download already in progress")
+
+// Download retry defaults, used for the zero DownloadOptions fields
+const (
+	DefaultDownloadRetries = 5
+	DefaultDownloadBackoff = time.Second
+	maxDownloadBackoff     = 30 * time.Second
+)
+
+// DownloadOptions tune DownloadModel
+type DownloadOptions struct {
+	SHA256  string        // expected hex digest of the file; empty skips the check
+	Retries int           // attempts after the first one fails; 0 uses DefaultDownloadRetries, -1 none
+	Backoff time.Duration // wait before the first retry, doubled for each further one
+}
+
 type WriteCounter struct {
-    Total      int64
-    Downloaded int64
-    OnProgress func(int64, int64) // callback
+	Total      int64
+	Downloaded int64
+	OnProgress func(int64, int64) // callback
 }
 
 func (wc *WriteCounter) Write(p []byte) (int, error) {
-    n := len(p)
-    wc.Downloaded += int64(n)
-    if wc.OnProgress != nil {
-        wc.OnProgress(wc.Downloaded, wc.Total)
-    }
-    return n, nil
+	n := len(p)
+	wc.Downloaded += int64(n)
+	if wc.OnProgress != nil {
+		wc.OnProgress(wc.Downloaded, wc.Total)
+	}
+	return n, nil
 }
 
-// NOTE: This is the primary and only definition for DownloadStatus in this package.
-// Any other definition (e.g., in manifest.go) must be removed to fix the "redeclared" compiler error.
 // DownloadStatus represents the download progress and is sent through a channel.
 type DownloadStatus struct {
-    ModelName   string
-    TotalBytes  int64
-    Downloaded  int64
-    Progress    float64
-    IsCompleted bool
-    Error       string
-}
-
-// DownloadModel fetches a GGUF file from a URL.
-// progressChan is used to send updates back to the UI.
-func DownloadModel(url string, filename string, progressChan chan<- DownloadStatus) (string, error) {
-    // Use a simple models directory in the current working directory
-    modelsDir := filepath.Join(".", "models")
-    
-    // Create models directory if it doesn't exist
-    if err := os.MkdirAll(modelsDir, 0755); err != nil {
-        return "", fmt.Errorf("failed to create models directory: %w", err)
-    }
-
-    destPath := filepath.Join(modelsDir, filename)
-    tempPath := destPath + ".tmp"
-
-    // Create the destination file
-    out, err := os.Create(tempPath)
-    if err != nil {
-        return "", fmt.Errorf("failed to create destination file: %w", err)
-    }
-    defer out.Close() // Ensure the file is closed even if errors occur
-
-    // Get the data from the URL
-    resp, err := http.Get(url)
-    if err != nil {
-        return "", fmt.Errorf("failed to get URL: %w", err)
-    }
-    defer resp.Body.Close() // Ensure the response body is closed
-
-    if resp.StatusCode != http.StatusOK {
-        return "", fmt.Errorf("bad status: %s", resp.Status)
-    }
-
-    // Initialize progress tracking
-    counter := &WriteCounter{
-        Total: resp.ContentLength,
-        OnProgress: func(current, total int64) {
-            var progress float64 = 0
-            if total > 0 {
-                progress = float64(current) / float64(total) * 100
-            }
-            status := DownloadStatus{
-                ModelName:   filename,
-                TotalBytes:  total,
-                Downloaded:  current,
-                Progress:    progress,
-                IsCompleted: false,
-            }
-            
-            // Non-blocking send to avoid slowing down the download
-            select {
-            case progressChan <- status:
-            default:
-            }
-        },
-    }
-
-    // Copy the response body to the file, while also passing it through the counter
-    if _, err = io.Copy(out, io.TeeReader(resp.Body, counter)); err != nil {
-        return "", fmt.Errorf("failed to download file: %w", err)
-    }
-
-    // Rename the temporary file to the final destination name
-    if err := os.Rename(tempPath, destPath); err != nil {
-        return "", fmt.Errorf("failed to rename temporary file: %w", err)
-    }
-
-    // Send a final success status
-    finalStatus := DownloadStatus{
-        ModelName:   filename,
-        TotalBytes:  counter.Total,
-        Downloaded:  counter.Downloaded,
-        Progress:    100,
-        IsCompleted: true,
-    }
-    
-    // Blocking send for the final message to ensure it's received
-    progressChan <- finalStatus
-
-    return destPath, nil
-}
\ No newline at end of file
+	ModelName   string
+	TotalBytes  int64
+	Downloaded  int64
+	Progress    float64
+	IsCompleted bool
+	Error       string
+	Retry       int // failed attempts so far; the download goes on after a backoff
+}
+
+// statusError is an HTTP response that is not the file
+type statusError struct {
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return "bad status: " + e.status
+}
+
+// retryable reports whether trying the download again may help. Client
+// errors other than timeouts and rate limits will not change.
+func retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusRequestTimeout || se.code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// DownloadModel fetches a GGUF file from a URL into the models directory.
+// Data is written to <filename>.tmp, so an interrupted download resumes
+// with an HTTP Range request, including from an earlier call. The resume
+// starts over if the file on the server has changed since. Failed
+// attempts are retried with exponential backoff. Cancelling ctx stops the
+// download and removes the partial file. progressChan is used to send
+// updates back to the UI.
+func DownloadModel(ctx context.Context, url string, filename string, opts DownloadOptions, progressChan chan<- DownloadStatus) (string, error) {
+	if err := checkFilename(filename); err != nil {
+		return "", err
+	}
+	modelsDir, err := modelsDir()
+	if err != nil {
+		return "", err
+	}
+	destPath := filepath.Join(modelsDir, filename)
+	tempPath := destPath + ".tmp"
+
+	// Non-blocking sends avoid slowing down the download
+	report := func(status DownloadStatus) {
+		status.ModelName = filename
+		if status.TotalBytes > 0 {
+			status.Progress = float64(status.Downloaded) / float64(status.TotalBytes) * 100
+		}
+		select {
+		case progressChan <- status:
+		default:
+		}
+	}
+
+	retries := opts.Retries
+	if retries == 0 {
+		retries = DefaultDownloadRetries
+	}
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = DefaultDownloadBackoff
+	}
+
+	var total int64 // last size the server announced
+	for attempt := 0; ; attempt++ {
+		err = fetch(ctx, url, tempPath, func(current, size int64) {
+			if size > 0 {
+				total = size
+			}
+			report(DownloadStatus{TotalBytes: total, Downloaded: current, Retry: attempt})
+		})
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			removePartial(tempPath)
+			return "", ctx.Err()
+		}
+		if attempt >= retries || !retryable(err) {
+			return "", fmt.Errorf("failed to download file: %w", err)
+		}
+
+		log.Printf("Download of %s failed, retrying: %v", filename, err)
+		// Progress stays where the next attempt resumes
+		kept := int64(0)
+		if info, err := os.Stat(tempPath); err == nil {
+			kept = info.Size()
+		}
+		report(DownloadStatus{TotalBytes: total, Downloaded: kept, Retry: attempt + 1})
+		select {
+		case <-time.After(min(backoff<<attempt, maxDownloadBackoff)):
+		case <-ctx.Done():
+			removePartial(tempPath)
+			return "", ctx.Err()
+		}
+	}
+
+	if opts.SHA256 != "" {
+		if err := verifySHA256(tempPath, opts.SHA256); err != nil {
+			// A corrupt file must not be resumed
+			removePartial(tempPath)
+			return "", err
+		}
+	}
+
+	// Rename the temporary file to the final destination name
+	if err := os.Rename(tempPath, destPath); err != nil {
+		return "", fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	os.Remove(validatorPath(tempPath))
+
+	// Blocking send for the final message to ensure it's received
+	size := int64(0)
+	if info, err := os.Stat(destPath); err == nil {
+		size = info.Size()
+	}
+	progressChan <- DownloadStatus{
+		ModelName:   filename,
+		TotalBytes:  size,
+		Downloaded:  size,
+		Progress:    100,
+		IsCompleted: true,
+	}
+
+	return destPath, nil
+}
+
+// checkFilename rejects names that would be written outside the models directory
+func checkFilename(filename string) error {
+	if filename == "" || filename == "." || filename == ".." || strings.ContainsAny(filename, `/\`) || strings.HasSuffix(filename, ".tmp") {
+		return fmt.Errorf("%w: %q is not a file name", ErrInvalidModelName, filename)
+	}
+	return nil
+}
+
+// removePartial deletes an unfinished download
+func removePartial(tempPath string) {
+	os.Remove(tempPath)
+	os.Remove(validatorPath(tempPath))
+}
+
+// validatorPath is where fetch keeps the If-Range value for the bytes in tempPath
+func validatorPath(tempPath string) string {
+	return tempPath + ".validator"
+}
+
+// fetch downloads url into tempPath, continuing after the bytes already
+// there as long as the file on the server is the one they came from
+func fetch(ctx context.Context, url, tempPath string, onProgress func(current, total int64)) error {
+	out, err := os.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer out.Close()
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// A changed file is then sent whole with 200 instead of appended
+		if validator, err := os.ReadFile(validatorPath(tempPath)); err == nil && len(validator) > 0 {
+			req.Header.Set("If-Range", string(validator))
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// A server that ignores the offset would corrupt the file
+		if start, ok := rangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			out.Truncate(0)
+			return fmt.Errorf("server resumed at %q instead of byte %d, starting over", resp.Header.Get("Content-Range"), offset)
+		}
+	case http.StatusOK:
+		// The server sends the whole file, so start over
+		if err := out.Truncate(0); err != nil {
+			return err
+		}
+		if offset, err = out.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Nothing after offset: the file is complete if that is its size
+		if total, ok := rangeTotal(resp.Header.Get("Content-Range")); ok && total == offset {
+			return nil
+		}
+		out.Truncate(0)
+		return &statusError{resp.Status, resp.StatusCode}
+	default:
+		return &statusError{resp.Status, resp.StatusCode}
+	}
+	if offset == 0 {
+		if err := saveValidator(tempPath, resp.Header); err != nil {
+			return err
+		}
+	}
+
+	counter := &WriteCounter{Downloaded: offset, OnProgress: onProgress}
+	if resp.ContentLength >= 0 {
+		counter.Total = offset + resp.ContentLength
+	}
+	written, err := io.Copy(out, io.TeeReader(resp.Body, counter))
+	if err != nil {
+		return err
+	}
+	if resp.ContentLength >= 0 && written < resp.ContentLength {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// saveValidator records what identifies the version of the file being
+// written to tempPath: a strong ETag, or else Last-Modified. Weak ETags
+// cannot be used with If-Range.
+func saveValidator(tempPath string, header http.Header) error {
+	validator := header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = header.Get("Last-Modified")
+	}
+	if validator == "" {
+		if err := os.Remove(validatorPath(tempPath)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(validatorPath(tempPath), []byte(validator), 0644)
+}
+
+// rangeStart reads the first byte from a "bytes <start>-<end>/<size>" Content-Range
+func rangeStart(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	return n, err == nil
+}
+
+// rangeTotal reads the full size from a "bytes */<size>" Content-Range
+func rangeTotal(header string) (int64, bool) {
+	_, size, ok := strings.Cut(header, "/")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	return n, err == nil
+}
+
+func verifySHA256(path, want string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%w: got sha256 %s, want %s", ErrChecksumMismatch, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/models/modeltest"
+)
+
+var fastRetry = DownloadOptions{Backoff: time.Millisecond}
+
+// serveFile serves data with Range support, like a static file server
+func serveFile(data []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "model.gguf", time.Time{}, bytes.NewReader(data))
+	}
+}
+
+func testData() []byte {
+	return bytes.Repeat([]byte("0123456789abcdef"), 4096)
+}
+
+func download(t *testing.T, ctx context.Context, url, name string, opts DownloadOptions) (string, error) {
+	t.Helper()
+	ch := make(chan DownloadStatus, 1)
+	go func() {
+		for range ch {
+		}
+	}()
+	defer close(ch)
+	return DownloadModel(ctx, url, name, opts, ch)
+}
+
+func checkFile(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s has %d bytes, want %d", path, len(got), len(want))
+	}
+	for _, leftover := range []string{path + ".tmp", path + ".tmp.validator"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("partial file left behind: %v", err)
+		}
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	dir := modeltest.Home(t)
+	data := testData()
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		serveFile(data)(w, r)
+	}))
+	defer srv.Close()
+
+	// The first half is left over from an interrupted download
+	if err := os.WriteFile(filepath.Join(dir, "m.gguf.tmp"), data[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := download(t, context.Background(), srv.URL, "m.gguf", fastRetry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "m.gguf") {
+		t.Errorf("path = %s, want it in the models directory %s", path, dir)
+	}
+	checkFile(t, path, data)
+	if len(ranges) != 1 || ranges[0] != "bytes=1000-" {
+		t.Errorf("Range headers = %q", ranges)
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	dir := modeltest.Home(t)
+	data := testData()
+	var requests atomic.Int32
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		switch requests.Add(1) {
+		case 1:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		case 2:
+			// The connection drops part way through the body
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data[:5000])
+		default:
+			serveFile(data)(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	path, err := download(t, context.Background(), srv.URL, "m.gguf", fastRetry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, filepath.Join(dir, "m.gguf"), data)
+	if path == "" || len(ranges) != 3 || ranges[2] != "bytes=5000-" {
+		t.Errorf("Range headers = %q", ranges)
+	}
+
+	// Client errors are not retried
+	requests.Store(0)
+	notFound := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer notFound.Close()
+	if _, err := download(t, context.Background(), notFound.URL, "missing.gguf", fastRetry); err == nil {
+		t.Error("download of a missing file succeeded")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests for a missing file, want 1", n)
+	}
+
+	// Nor is anything once the retries are used up
+	requests.Store(0)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if _, err := download(t, context.Background(), failing.URL, "down.gguf", DownloadOptions{Retries: 2, Backoff: time.Millisecond}); err == nil {
+		t.Error("download from a failing server succeeded")
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("%d requests with 2 retries, want 3", n)
+	}
+}
+
+func TestDownloadRetryProgress(t *testing.T) {
+	modeltest.Home(t)
+	data := testData()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data[:5000])
+			return
+		}
+		serveFile(data)(w, r)
+	}))
+	defer srv.Close()
+
+	ch := make(chan DownloadStatus, 1000)
+	if _, err := DownloadModel(context.Background(), srv.URL, "m.gguf", fastRetry, ch); err != nil {
+		t.Fatal(err)
+	}
+	close(ch)
+
+	// The retry keeps the size and the bytes already on disk, so a progress
+	// bar does not jump back to zero
+	var retry *DownloadStatus
+	for status := range ch {
+		if status.Retry == 1 && retry == nil {
+			retry = &status
+		}
+	}
+	if retry == nil || retry.TotalBytes != int64(len(data)) || retry.Downloaded != 5000 {
+		t.Errorf("retry status = %+v, want %d of %d bytes", retry, 5000, len(data))
+	}
+}
+
+func TestDownloadRangeMismatch(t *testing.T) {
+	dir := modeltest.Home(t)
+	data := testData()
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.Header.Get("Range") == "" {
+			serveFile(data)(w, r)
+			return
+		}
+		// A broken server answers the resume with the file from the start
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(data)-1, len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "m.gguf.tmp"), data[:1000], 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := download(t, context.Background(), srv.URL, "m.gguf", fastRetry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, path, data)
+	if len(ranges) != 2 || ranges[0] != "bytes=1000-" || ranges[1] != "" {
+		t.Errorf("Range headers = %q, want a restart from zero", ranges)
+	}
+}
+
+func TestDownloadFileChanged(t *testing.T) {
+	modeltest.Home(t)
+	oldData, newData := testData(), bytes.Repeat([]byte("fedcba9876543210"), 4096)
+	var requests atomic.Int32
+	var ifRanges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+		if requests.Add(1) == 1 {
+			// The first attempt gets part of the old file
+			w.Header().Set("ETag", `"old"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(oldData)))
+			w.Write(oldData[:5000])
+			return
+		}
+		// Then the file is replaced, which a resume must not append to
+		w.Header().Set("ETag", `"new"`)
+		serveFile(newData)(w, r)
+	}))
+	defer srv.Close()
+
+	path, err := download(t, context.Background(), srv.URL, "m.gguf", fastRetry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, path, newData)
+	if len(ifRanges) != 2 || ifRanges[1] != `"old"` {
+		t.Errorf("If-Range headers = %q, want the first ETag on the resume", ifRanges)
+	}
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	dir := modeltest.Home(t)
+	data := testData()
+	sum := sha256.Sum256(data)
+	srv := httptest.NewServer(serveFile(data))
+	defer srv.Close()
+
+	opts := fastRetry
+	opts.SHA256 = hex.EncodeToString(make([]byte, 32))
+	_, err := download(t, context.Background(), srv.URL, "bad.gguf", opts)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("err = %v, want ErrChecksumMismatch", err)
+	}
+	for _, name := range []string{"bad.gguf", "bad.gguf.tmp"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s kept after a checksum mismatch", name)
+		}
+	}
+
+	opts.SHA256 = hex.EncodeToString(sum[:])
+	path, err := download(t, context.Background(), srv.URL, "good.gguf", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, path, data)
+}
+
+func TestDownloadCancel(t *testing.T) {
+	dir := modeltest.Home(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000000")
+		w.Write(make([]byte, 1000))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan DownloadStatus, 100)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := DownloadModel(ctx, srv.URL, "m.gguf", fastRetry, ch)
+		errc <- err
+	}()
+	// Cancel once the first bytes are on disk
+	for status := range ch {
+		if status.Downloaded > 0 {
+			break
+		}
+	}
+	cancel()
+
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "m.gguf.tmp")); !os.IsNotExist(err) {
+		t.Errorf("partial file kept after cancel: %v", err)
+	}
+}
+
+func TestDownloadInvalidName(t *testing.T) {
+	modeltest.Home(t)
+	m := NewManager()
+	for _, name := range []string{"", "..", "../m.gguf", `dir\m.gguf`, "m.gguf.tmp"} {
+		if _, err := m.Download(context.Background(), "http://localhost/m.gguf", name, DownloadOptions{}); !errors.Is(err, ErrInvalidModelName) {
+			t.Errorf("Download(%q) err = %v, want ErrInvalidModelName", name, err)
+		}
+	}
+}
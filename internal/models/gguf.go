@@ -0,0 +1,402 @@
+package models
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// GGUF file layout: https://github.com/ggml-org/ggml/blob/master/docs/gguf.md
+const ggufMagic = 0x46554747 // "GGUF" in little endian
+
+// ErrNotGGUF is returned when a file does not start with the GGUF magic number
+var ErrNotGGUF = errors.New("not a GGUF file")
+
+// GGUF metadata value types
+const (
+	ggufTypeUint8 uint32 = iota
+	ggufTypeInt8
+	ggufTypeUint16
+	ggufTypeInt16
+	ggufTypeUint32
+	ggufTypeInt32
+	ggufTypeFloat32
+	ggufTypeBool
+	ggufTypeString
+	ggufTypeArray
+	ggufTypeUint64
+	ggufTypeInt64
+	ggufTypeFloat64
+)
+
+// maxArrayValues bounds how many elements of a metadata array are kept in memory.
+// Larger arrays (e.g. the tokenizer vocabulary) only report their length.
+const maxArrayValues = 1024
+
+// GGUFArray is a metadata array value
+type GGUFArray struct {
+	Type   uint32 `json:"type"` // element type
+	Len    uint64 `json:"len"`
+	Values []any  `json:"values"` // nil when Len exceeds maxArrayValues
+}
+
+// GGUFTensor describes one tensor stored in the file (its data is not read)
+type GGUFTensor struct {
+	Name   string   `json:"name"`
+	Shape  []uint64 `json:"shape"`
+	Type   string   `json:"type"`
+	Offset uint64   `json:"offset"` // relative to the start of the tensor data section
+}
+
+// Elements returns the number of values in the tensor
+func (t GGUFTensor) Elements() uint64 {
+	n := uint64(1)
+	for _, d := range t.Shape {
+		n *= d
+	}
+	return n
+}
+
+// GGUFFile is the parsed header of a GGUF model: metadata and tensor table
+type GGUFFile struct {
+	Version  uint32
+	Metadata map[string]any
+	Tensors  []GGUFTensor
+}
+
+// ParseGGUF reads the header, metadata and tensor table of a GGUF file
+// without loading any tensor data
+func ParseGGUF(path string) (*GGUFFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ggufReader{r: bufio.NewReader(f)}
+
+	var magic uint32
+	r.read(&magic)
+	if r.err != nil || magic != ggufMagic {
+		return nil, ErrNotGGUF
+	}
+
+	file := &GGUFFile{}
+	r.read(&file.Version)
+	if file.Version < 2 || file.Version > 3 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", file.Version)
+	}
+
+	var tensorCount, kvCount uint64
+	r.read(&tensorCount)
+	r.read(&kvCount)
+	if r.err != nil {
+		return nil, fmt.Errorf("corrupt GGUF header: %w", r.err)
+	}
+
+	// Every entry needs at least a few bytes, so counts beyond the file size are corruption
+	if tensorCount > uint64(stat.Size()) || kvCount > uint64(stat.Size()) {
+		return nil, fmt.Errorf("corrupt GGUF header: implausible counts (%d tensors, %d keys)", tensorCount, kvCount)
+	}
+
+	// 1. Key/value metadata. The counts come from the file, so nothing is
+	// sized by them up front: a corrupt header fails when the data runs out.
+	file.Metadata = make(map[string]any)
+	for i := uint64(0); i < kvCount; i++ {
+		key := r.string()
+		var typ uint32
+		r.read(&typ)
+		value := r.value(typ)
+		if r.err != nil {
+			return nil, fmt.Errorf("corrupt GGUF metadata: %w", r.err)
+		}
+		file.Metadata[key] = value
+	}
+
+	// 2. Tensor table
+	alignment := file.Uint("general.alignment")
+	if alignment == 0 {
+		alignment = 32
+	}
+	file.Tensors = []GGUFTensor{}
+	for i := uint64(0); i < tensorCount; i++ {
+		var t GGUFTensor
+		t.Name = r.string()
+
+		var nDims, typ uint32
+		r.read(&nDims)
+		if r.err == nil && nDims > 4 {
+			return nil, fmt.Errorf("corrupt GGUF tensor %q: %d dimensions", t.Name, nDims)
+		}
+		t.Shape = make([]uint64, nDims)
+		for d := range t.Shape {
+			r.read(&t.Shape[d])
+		}
+		r.read(&typ)
+		r.read(&t.Offset)
+		if r.err != nil {
+			return nil, fmt.Errorf("corrupt GGUF tensor table: %w", r.err)
+		}
+
+		t.Type = GGMLTypeName(typ)
+		if t.Offset%alignment != 0 || t.Offset >= uint64(stat.Size()) {
+			return nil, fmt.Errorf("corrupt GGUF tensor %q: bad data offset %d", t.Name, t.Offset)
+		}
+		file.Tensors = append(file.Tensors, t)
+	}
+
+	return file, nil
+}
+
+// String returns a string metadata value, or "" if missing
+func (f *GGUFFile) String(key string) string {
+	s, _ := f.Metadata[key].(string)
+	return s
+}
+
+// Uint returns an integer metadata value of any width, or 0 if missing
+func (f *GGUFFile) Uint(key string) uint64 {
+	switch v := f.Metadata[key].(type) {
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case int8:
+		return uint64(max(v, 0))
+	case int16:
+		return uint64(max(v, 0))
+	case int32:
+		return uint64(max(v, 0))
+	case int64:
+		return uint64(max(v, 0))
+	}
+	return 0
+}
+
+// Float returns a floating point metadata value, or 0 if missing
+func (f *GGUFFile) Float(key string) float64 {
+	switch v := f.Metadata[key].(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// Architecture returns general.architecture, e.g. "llama" or "bitnet"
+func (f *GGUFFile) Architecture() string {
+	return f.String("general.architecture")
+}
+
+// ContextLength returns the context size the model was trained with
+func (f *GGUFFile) ContextLength() uint64 {
+	return f.Uint(f.Architecture() + ".context_length")
+}
+
+// VocabSize returns the number of tokens in the tokenizer vocabulary
+func (f *GGUFFile) VocabSize() uint64 {
+	if tokens, ok := f.Metadata["tokenizer.ggml.tokens"].(GGUFArray); ok {
+		return tokens.Len
+	}
+	return f.Uint(f.Architecture() + ".vocab_size")
+}
+
+// ParameterCount returns general.parameter_count, or the sum of all tensor sizes
+func (f *GGUFFile) ParameterCount() uint64 {
+	if n := f.Uint("general.parameter_count"); n > 0 {
+		return n
+	}
+	var n uint64
+	for _, t := range f.Tensors {
+		n += t.Elements()
+	}
+	return n
+}
+
+// Quantization names the file's quantization scheme, from general.file_type
+// or, failing that, the tensor type holding the most weights
+func (f *GGUFFile) Quantization() string {
+	if _, ok := f.Metadata["general.file_type"]; ok {
+		if name, known := fileTypeNames[f.Uint("general.file_type")]; known {
+			return name
+		}
+	}
+
+	counts := make(map[string]uint64)
+	best := ""
+	for _, t := range f.Tensors {
+		counts[t.Type] += t.Elements()
+		if counts[t.Type] > counts[best] {
+			best = t.Type
+		}
+	}
+	return best
+}
+
+// fileTypeNames maps general.file_type (llama_ftype) values to their names
+var fileTypeNames = map[uint64]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16", 36: "TQ1_0", 37: "TQ2_0",
+}
+
+// ggmlTypeNames maps ggml tensor types to their names
+var ggmlTypeNames = map[uint32]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 6: "Q5_0", 7: "Q5_1", 8: "Q8_0", 9: "Q8_1",
+	10: "Q2_K", 11: "Q3_K", 12: "Q4_K", 13: "Q5_K", 14: "Q6_K", 15: "Q8_K",
+	16: "IQ2_XXS", 17: "IQ2_XS", 18: "IQ3_XXS", 19: "IQ1_S", 20: "IQ4_NL", 21: "IQ3_S",
+	22: "IQ2_S", 23: "IQ4_XS", 24: "I8", 25: "I16", 26: "I32", 27: "I64", 28: "F64",
+	29: "IQ1_M", 30: "BF16", 34: "TQ1_0", 35: "TQ2_0", 36: "I2_S",
+}
+
+// GGMLTypeName returns the name of a ggml tensor type
+func GGMLTypeName(t uint32) string {
+	if name, ok := ggmlTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type_%d", t)
+}
+
+// FormatParameterCount renders a parameter count the way model cards do, e.g. "2.4B"
+func FormatParameterCount(n uint64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.0fM", float64(n)/1e6)
+	case n > 0:
+		return fmt.Sprintf("%.0fK", float64(n)/1e3)
+	}
+	return ""
+}
+
+// ggufReader decodes little-endian GGUF primitives and remembers the first error
+type ggufReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (g *ggufReader) read(v any) {
+	if g.err != nil {
+		return
+	}
+	g.err = binary.Read(g.r, binary.LittleEndian, v)
+}
+
+func (g *ggufReader) string() string {
+	var n uint64
+	g.read(&n)
+	if g.err != nil {
+		return ""
+	}
+	// No sane key or value is this large; treat it as corruption
+	if n > 64<<20 {
+		g.err = fmt.Errorf("string length %d out of range", n)
+		return ""
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(g.r, buf); err != nil {
+		g.err = err
+		return ""
+	}
+	return string(buf)
+}
+
+func (g *ggufReader) value(typ uint32) any {
+	switch typ {
+	case ggufTypeUint8:
+		var v uint8
+		g.read(&v)
+		return v
+	case ggufTypeInt8:
+		var v int8
+		g.read(&v)
+		return v
+	case ggufTypeUint16:
+		var v uint16
+		g.read(&v)
+		return v
+	case ggufTypeInt16:
+		var v int16
+		g.read(&v)
+		return v
+	case ggufTypeUint32:
+		var v uint32
+		g.read(&v)
+		return v
+	case ggufTypeInt32:
+		var v int32
+		g.read(&v)
+		return v
+	case ggufTypeFloat32:
+		var v uint32
+		g.read(&v)
+		return math.Float32frombits(v)
+	case ggufTypeBool:
+		var v uint8
+		g.read(&v)
+		return v != 0
+	case ggufTypeString:
+		return g.string()
+	case ggufTypeArray:
+		return g.array()
+	case ggufTypeUint64:
+		var v uint64
+		g.read(&v)
+		return v
+	case ggufTypeInt64:
+		var v int64
+		g.read(&v)
+		return v
+	case ggufTypeFloat64:
+		var v uint64
+		g.read(&v)
+		return math.Float64frombits(v)
+	default:
+		if g.err == nil {
+			g.err = fmt.Errorf("unknown metadata type %d", typ)
+		}
+		return nil
+	}
+}
+
+func (g *ggufReader) array() GGUFArray {
+	var arr GGUFArray
+	g.read(&arr.Type)
+	g.read(&arr.Len)
+	if g.err != nil {
+		return arr
+	}
+	if arr.Type == ggufTypeArray {
+		g.err = fmt.Errorf("nested arrays are not supported")
+		return arr
+	}
+
+	keep := arr.Len <= maxArrayValues
+	if keep {
+		arr.Values = make([]any, 0, arr.Len)
+	}
+	for i := uint64(0); i < arr.Len && g.err == nil; i++ {
+		v := g.value(arr.Type)
+		if keep {
+			arr.Values = append(arr.Values, v)
+		}
+	}
+	return arr
+}
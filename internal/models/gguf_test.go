@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/models/modeltest"
+)
+
+// ggufFixture writes a small valid GGUF file and returns its path and contents
+func ggufFixture(t *testing.T) (string, []byte) {
+	t.Helper()
+	path := modeltest.WriteGGUF(t, filepath.Join(t.TempDir(), "tiny.gguf"), map[string]any{
+		"general.architecture":  "bitnet",
+		"general.name":          "tiny",
+		"bitnet.context_length": uint32(4096),
+		"general.file_type":     uint64(1),
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func TestParseGGUF(t *testing.T) {
+	path, _ := ggufFixture(t)
+	f, err := ParseGGUF(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Version != 3 || len(f.Metadata) != 4 || len(f.Tensors) != 0 {
+		t.Errorf("parsed %+v", f)
+	}
+	if f.Architecture() != "bitnet" || f.String("general.name") != "tiny" {
+		t.Errorf("architecture %q, name %q", f.Architecture(), f.String("general.name"))
+	}
+	if f.ContextLength() != 4096 || f.Quantization() != "F16" {
+		t.Errorf("context length %d, quantization %q", f.ContextLength(), f.Quantization())
+	}
+}
+
+func TestParseGGUFCorrupt(t *testing.T) {
+	path, data := ggufFixture(t)
+
+	// The header is magic, version, tensor count and key count
+	withCount := func(offset int, n uint64) []byte {
+		b := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint64(b[offset:], n)
+		return b
+	}
+	badMagic := append([]byte("GGML"), data[4:]...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string // part of the error; empty for ErrNotGGUF
+	}{
+		{"bad magic", badMagic, ""},
+		{"empty", nil, ""},
+		{"truncated header", data[:12], "corrupt GGUF header"},
+		{"truncated metadata", data[:len(data)-3], "corrupt GGUF metadata"},
+		{"key count beyond the file size", withCount(16, 1<<40), "implausible counts"},
+		{"tensor count beyond the file size", withCount(8, 1<<62), "implausible counts"},
+		{"key count beyond the entries", withCount(16, uint64(len(data))), "corrupt GGUF metadata"},
+		{"tensor count beyond the entries", withCount(8, uint64(len(data))), "corrupt GGUF tensor table"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(path, tt.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ParseGGUF(path)
+		switch {
+		case tt.want == "" && !errors.Is(err, ErrNotGGUF):
+			t.Errorf("%s: err = %v, want ErrNotGGUF", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+)
+
+// EnvHFEndpoint names the environment variable that points Hugging Face
+// lookups at a mirror, as in the huggingface_hub tools
+const EnvHFEndpoint = "HF_ENDPOINT"
+
+// DefaultHFEndpoint is the Hugging Face Hub
+const DefaultHFEndpoint = "https://huggingface.co"
+
+// ErrInvalidReference is returned for a model reference that does not name one GGUF file
+var ErrInvalidReference = errors.New("invalid model reference")
+
+// preferredQuants are picked, in order, from a repository with several GGUF
+// files when the reference names none. i2_s is the BitNet format.
+var preferredQuants = []string{"i2_s", "tl2", "tl1", "q4_k_m", "q4_0", "q8_0"}
+
+var hfRepoPattern = regexp.MustCompile(`^[A-Za-z0-9][\w.-]*/[A-Za-z0-9][\w.-]*$`)
+
+// HFReference names a GGUF file in a Hugging Face repository
+type HFReference struct {
+	Repo  string // org/repo
+	File  string // path of the file in the repository; empty picks one
+	Quant string // quantization to pick when File is empty, such as i2_s or Q4_K_M
+}
+
+// ParseHFReference reads hf://org/repo[:file], hf.co/org/repo[:quant] or
+// org/repo[:quant]. A part after the colon ending in .gguf is a file,
+// anything else a quantization.
+func ParseHFReference(ref string) (HFReference, error) {
+	s := ref
+	for _, prefix := range []string{"hf://", "hf.co/", "huggingface.co/"} {
+		if rest, ok := strings.CutPrefix(s, prefix); ok {
+			s = rest
+			break
+		}
+	}
+	repo, selector, _ := strings.Cut(s, ":")
+	if !hfRepoPattern.MatchString(repo) {
+		return HFReference{}, fmt.Errorf("%w: %q (use hf://org/repo[:file] or org/repo:quant)", ErrInvalidReference, ref)
+	}
+
+	r := HFReference{Repo: repo}
+	if strings.HasSuffix(strings.ToLower(selector), ".gguf") {
+		r.File = selector
+	} else {
+		r.Quant = selector
+	}
+	return r, nil
+}
+
+func (r HFReference) String() string {
+	switch {
+	case r.File != "":
+		return "hf://" + r.Repo + ":" + r.File
+	case r.Quant != "":
+		return "hf://" + r.Repo + ":" + r.Quant
+	}
+	return "hf://" + r.Repo
+}
+
+// RemoteFile is a GGUF file to download
+type RemoteFile struct {
+	URL      string `json:"url"`
+	Filename string `json:"filename"`         // name in the models directory
+	Size     int64  `json:"size,omitempty"`   // 0 if unknown
+	SHA256   string `json:"sha256,omitempty"` // empty if unknown
+}
+
+// HFResolver looks up model references on the Hugging Face Hub or a mirror
+// with the same API
+type HFResolver struct {
+	Endpoint string
+	Client   *http.Client // nil uses http.DefaultClient
+}
+
+// NewHFResolver uses $HF_ENDPOINT, then huggingface.endpoint from the config
+// file, then the Hub
+func NewHFResolver(file *config.Config) *HFResolver {
+	endpoint := os.Getenv(EnvHFEndpoint)
+	if endpoint == "" && file != nil {
+		endpoint = file.HuggingFace.Endpoint
+	}
+	if endpoint == "" {
+		endpoint = DefaultHFEndpoint
+	}
+	return &HFResolver{Endpoint: strings.TrimRight(endpoint, "/")}
+}
+
+// Resolve turns a model reference into the file to download. A direct
+// http(s) URL is used as it is; without a name to save it as, it has to
+// point to a .gguf file. Anything else is a Hugging Face reference, see
+// ParseHFReference. A non-empty name replaces the remote file name.
+func (r *HFResolver) Resolve(ctx context.Context, ref, name string) (RemoteFile, error) {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return RemoteFile{}, fmt.Errorf("%w: %v", ErrInvalidReference, err)
+		}
+		if name == "" {
+			name = path.Base(u.Path)
+			if !strings.HasSuffix(strings.ToLower(name), ".gguf") {
+				return RemoteFile{}, fmt.Errorf("%w: URL does not point to a .gguf file", ErrInvalidReference)
+			}
+		}
+		return RemoteFile{URL: ref, Filename: name}, nil
+	}
+
+	hf, err := ParseHFReference(ref)
+	if err != nil {
+		return RemoteFile{}, err
+	}
+	files, err := r.ListGGUF(ctx, hf.Repo)
+	if err != nil {
+		return RemoteFile{}, err
+	}
+	file, err := pickFile(hf, files)
+	if err != nil {
+		return RemoteFile{}, err
+	}
+	if name == "" {
+		name = path.Base(file.Path)
+	}
+	return RemoteFile{
+		URL:      r.Endpoint + "/" + hf.Repo + "/resolve/main/" + escapePath(file.Path),
+		Filename: name,
+		Size:     file.Size,
+		SHA256:   file.SHA256,
+	}, nil
+}
+
+// HFFile is a GGUF file in a repository
+type HFFile struct {
+	Path   string
+	Size   int64
+	SHA256 string // of files stored with Git LFS; empty otherwise
+}
+
+// ListGGUF returns the GGUF files on the main branch of repo
+func (r *HFResolver) ListGGUF(ctx context.Context, repo string) ([]HFFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.Endpoint+"/api/models/"+repo+"/tree/main?recursive=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", repo, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusUnauthorized:
+		// The Hub answers 401 for repositories that do not exist
+		return nil, fmt.Errorf("%w: no Hugging Face repository %s", ErrModelNotFound, repo)
+	default:
+		return nil, fmt.Errorf("listing %s: %s", repo, resp.Status)
+	}
+
+	var entries []struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+		LFS  *struct {
+			OID  string `json:"oid"`
+			Size int64  `json:"size"`
+		} `json:"lfs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("listing %s: %w", repo, err)
+	}
+
+	var files []HFFile
+	for _, e := range entries {
+		if e.Type != "file" || !strings.HasSuffix(strings.ToLower(e.Path), ".gguf") {
+			continue
+		}
+		file := HFFile{Path: e.Path, Size: e.Size}
+		if e.LFS != nil {
+			file.Size = e.LFS.Size
+			file.SHA256 = e.LFS.OID
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// pickFile finds the file ref names among files, or the one quantization
+// to use when it names none
+func pickFile(ref HFReference, files []HFFile) (HFFile, error) {
+	if len(files) == 0 {
+		return HFFile{}, fmt.Errorf("%w: %s has no GGUF files", ErrModelNotFound, ref.Repo)
+	}
+
+	if ref.File != "" {
+		var byName []HFFile
+		for _, f := range files {
+			if f.Path == ref.File {
+				return f, nil
+			}
+			if path.Base(f.Path) == ref.File {
+				byName = append(byName, f)
+			}
+		}
+		if len(byName) == 1 {
+			return byName[0], nil
+		}
+		if len(byName) == 0 {
+			return HFFile{}, fmt.Errorf("%w: %s has no file %s (has %s)", ErrModelNotFound, ref.Repo, ref.File, fileList(files))
+		}
+		return HFFile{}, fmt.Errorf("%w: several files in %s are named %s (%s)", ErrInvalidReference, ref.Repo, ref.File, fileList(byName))
+	}
+
+	if ref.Quant != "" {
+		matches := withQuant(files, ref.Quant)
+		switch len(matches) {
+		case 1:
+			return matches[0], nil
+		case 0:
+			return HFFile{}, fmt.Errorf("%w: %s has no %s file (has %s)", ErrModelNotFound, ref.Repo, ref.Quant, fileList(files))
+		}
+		return HFFile{}, fmt.Errorf("%w: several %s files in %s (%s); name one with hf://%s:<file>", ErrInvalidReference, ref.Quant, ref.Repo, fileList(matches), ref.Repo)
+	}
+
+	if len(files) == 1 {
+		return files[0], nil
+	}
+	for _, quant := range preferredQuants {
+		if matches := withQuant(files, quant); len(matches) == 1 {
+			return matches[0], nil
+		}
+	}
+	return HFFile{}, fmt.Errorf("%w: %s has several GGUF files (%s); pick one with %s:<quant>", ErrInvalidReference, ref.Repo, fileList(files), ref.Repo)
+}
+
+// withQuant returns the files whose name contains quant as a whole part,
+// between '-', '.' or '_' separators
+func withQuant(files []HFFile, quant string) []HFFile {
+	quant = strings.ToLower(quant)
+	var matches []HFFile
+	for _, f := range files {
+		name := strings.TrimSuffix(strings.ToLower(path.Base(f.Path)), ".gguf")
+		for i := 0; i <= len(name)-len(quant); i++ {
+			j := i + len(quant)
+			if name[i:j] == quant && (i == 0 || isNameSeparator(name[i-1])) && (j == len(name) || isNameSeparator(name[j])) {
+				matches = append(matches, f)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func isNameSeparator(c byte) bool {
+	return c == '-' || c == '.' || c == '_'
+}
+
+func fileList(files []HFFile) string {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Path
+	}
+	return strings.Join(names, ", ")
+}
+
+// escapePath escapes each segment of a path inside a repository
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+)
+
+func TestParseHFReference(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want HFReference
+	}{
+		{"hf://microsoft/bitnet-b1.58-2B-4T-gguf", HFReference{Repo: "microsoft/bitnet-b1.58-2B-4T-gguf"}},
+		{"hf://org/repo:ggml-model-i2_s.gguf", HFReference{Repo: "org/repo", File: "ggml-model-i2_s.gguf"}},
+		{"hf://org/repo:sub/dir/model.GGUF", HFReference{Repo: "org/repo", File: "sub/dir/model.GGUF"}},
+		{"org/repo:Q4_K_M", HFReference{Repo: "org/repo", Quant: "Q4_K_M"}},
+		{"hf.co/org/repo:i2_s", HFReference{Repo: "org/repo", Quant: "i2_s"}},
+		{"org/repo", HFReference{Repo: "org/repo"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseHFReference(tt.ref)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseHFReference(%q) = %+v, %v; want %+v", tt.ref, got, err, tt.want)
+		}
+	}
+
+	for _, ref := range []string{"", "repo", "hf://repo:i2_s", "org/repo/extra", "../org/repo", "org/repo name"} {
+		if _, err := ParseHFReference(ref); !errors.Is(err, ErrInvalidReference) {
+			t.Errorf("ParseHFReference(%q) err = %v, want ErrInvalidReference", ref, err)
+		}
+	}
+}
+
+// hfStub answers the tree listing of one repository, org/repo
+func hfStub(t *testing.T, entries []map[string]any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/models/org/repo/tree/main" || r.URL.Query().Get("recursive") != "true" {
+			http.Error(w, `{"error":"Repository not found"}`, http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(entries)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHFResolve(t *testing.T) {
+	srv := hfStub(t, []map[string]any{
+		{"type": "file", "path": "README.md", "size": 100},
+		{"type": "directory", "path": "sub"},
+		{"type": "file", "path": "ggml-model-tl1.gguf", "size": 10, "lfs": map[string]any{"oid": "aaa", "size": 1000}},
+		{"type": "file", "path": "ggml-model-i2_s.gguf", "size": 10, "lfs": map[string]any{"oid": "bbb", "size": 2000}},
+		{"type": "file", "path": "sub/model-Q4_K_M.gguf", "size": 3000},
+		{"type": "file", "path": "sub/model-Q4_K_S.gguf", "size": 3000},
+	})
+	r := &HFResolver{Endpoint: srv.URL}
+	ctx := context.Background()
+
+	tests := []struct {
+		ref  string
+		want RemoteFile
+	}{
+		// i2_s is preferred over the other quantizations
+		{"hf://org/repo", RemoteFile{URL: srv.URL + "/org/repo/resolve/main/ggml-model-i2_s.gguf", Filename: "ggml-model-i2_s.gguf", Size: 2000, SHA256: "bbb"}},
+		{"org/repo:TL1", RemoteFile{URL: srv.URL + "/org/repo/resolve/main/ggml-model-tl1.gguf", Filename: "ggml-model-tl1.gguf", Size: 1000, SHA256: "aaa"}},
+		{"org/repo:q4_k_m", RemoteFile{URL: srv.URL + "/org/repo/resolve/main/sub/model-Q4_K_M.gguf", Filename: "model-Q4_K_M.gguf", Size: 3000}},
+		{"hf://org/repo:model-Q4_K_S.gguf", RemoteFile{URL: srv.URL + "/org/repo/resolve/main/sub/model-Q4_K_S.gguf", Filename: "model-Q4_K_S.gguf", Size: 3000}},
+		{"https://example.com/files/tiny.gguf", RemoteFile{URL: "https://example.com/files/tiny.gguf", Filename: "tiny.gguf"}},
+	}
+	for _, tt := range tests {
+		got, err := r.Resolve(ctx, tt.ref, "")
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %+v, %v; want %+v", tt.ref, got, err, tt.want)
+		}
+	}
+
+	errorTests := []struct {
+		ref  string
+		want error
+	}{
+		{"org/repo:q8_0", ErrModelNotFound},
+		{"hf://org/repo:other.gguf", ErrModelNotFound},
+		{"org/missing", ErrModelNotFound},
+		{"org/repo:q4_k", ErrInvalidReference}, // Q4_K_M or Q4_K_S
+		{"https://example.com/model.bin", ErrInvalidReference},
+	}
+	for _, tt := range errorTests {
+		if _, err := r.Resolve(ctx, tt.ref, ""); !errors.Is(err, tt.want) {
+			t.Errorf("Resolve(%q) err = %v, want %v", tt.ref, err, tt.want)
+		}
+	}
+
+	// A name to save the file as replaces the remote one, and lets a direct
+	// URL point anywhere
+	named := []struct {
+		ref  string
+		want RemoteFile
+	}{
+		{"https://example.com/download?id=7", RemoteFile{URL: "https://example.com/download?id=7", Filename: "mine.gguf"}},
+		{"org/repo:TL1", RemoteFile{URL: srv.URL + "/org/repo/resolve/main/ggml-model-tl1.gguf", Filename: "mine.gguf", Size: 1000, SHA256: "aaa"}},
+	}
+	for _, tt := range named {
+		got, err := r.Resolve(ctx, tt.ref, "mine.gguf")
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q, mine.gguf) = %+v, %v; want %+v", tt.ref, got, err, tt.want)
+		}
+	}
+
+	// Several files and none of the preferred quantizations
+	srv = hfStub(t, []map[string]any{
+		{"type": "file", "path": "a-f16.gguf"},
+		{"type": "file", "path": "a-q5_1.gguf"},
+	})
+	if _, err := (&HFResolver{Endpoint: srv.URL}).Resolve(ctx, "org/repo", ""); !errors.Is(err, ErrInvalidReference) {
+		t.Errorf("ambiguous repository: err = %v", err)
+	}
+}
+
+func TestNewHFResolver(t *testing.T) {
+	t.Setenv(EnvHFEndpoint, "")
+	if r := NewHFResolver(nil); r.Endpoint != DefaultHFEndpoint {
+		t.Errorf("default endpoint = %q", r.Endpoint)
+	}
+	file := &config.Config{HuggingFace: config.HuggingFaceConfig{Endpoint: "http://mirror.local/"}}
+	if r := NewHFResolver(file); r.Endpoint != "http://mirror.local" {
+		t.Errorf("config endpoint = %q", r.Endpoint)
+	}
+	t.Setenv(EnvHFEndpoint, "http://env.local")
+	if r := NewHFResolver(file); r.Endpoint != "http://env.local" {
+		t.Errorf("$HF_ENDPOINT = %q", r.Endpoint)
+	}
+}
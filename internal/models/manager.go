@@ -1,15 +1,24 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 )
 
+// ErrModelNotFound is returned when a model ID does not match any installed model
+var ErrModelNotFound = errors.New("model not found")
+
 type Manager struct {
 	mu sync.Mutex
+
+	downloadsMu sync.Mutex
+	downloads   map[string]bool // file names being downloaded
 }
 
 func NewManager() *Manager {
-	return &Manager{}
+	return &Manager{downloads: make(map[string]bool)}
 }
 
 func (m *Manager) List() ([]ModelInfo, error) {
@@ -18,14 +27,50 @@ func (m *Manager) List() ([]ModelInfo, error) {
 	return ScanModels()
 }
 
-func (m *Manager) Download(url string, name string) (<-chan DownloadStatus, error) {
+// Find resolves a model by its ID or filename
+func (m *Manager) Find(id string) (ModelInfo, error) {
+	list, err := m.List()
+	if err != nil {
+		return ModelInfo{}, err
+	}
+
+	for _, info := range list {
+		if info.ID == id || info.Filename == id {
+			return info, nil
+		}
+	}
+	return ModelInfo{}, fmt.Errorf("%w: %s", ErrModelNotFound, id)
+}
+
+// Download fetches url into the models directory as name in the background.
+// The channel reports progress and is closed when the download ends; a
+// failure is sent as a status with Error set. Cancelling ctx aborts the
+// download and removes the partial file.
+func (m *Manager) Download(ctx context.Context, url string, name string, opts DownloadOptions) (<-chan DownloadStatus, error) {
+	if err := checkFilename(name); err != nil {
+		return nil, err
+	}
+	// Two downloads into the same .tmp file would corrupt it
+	m.downloadsMu.Lock()
+	if m.downloads[name] {
+		m.downloadsMu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrDownloadInProgress, name)
+	}
+	m.downloads[name] = true
+	m.downloadsMu.Unlock()
+
 	// Create a channel for updates
 	ch := make(chan DownloadStatus, 100)
 
 	// Run download in background
 	go func() {
 		defer close(ch)
-		_, err := DownloadModel(url, name, ch)
+		defer func() {
+			m.downloadsMu.Lock()
+			delete(m.downloads, name)
+			m.downloadsMu.Unlock()
+		}()
+		_, err := DownloadModel(ctx, url, name, opts, ch)
 		if err != nil {
 			ch <- DownloadStatus{
 				ModelName: name,
@@ -35,4 +80,4 @@ func (m *Manager) Download(url string, name string) (<-chan DownloadStatus, erro
 	}()
 
 	return ch, nil
-}
\ No newline at end of file
+}
@@ -11,6 +11,16 @@ type ModelInfo struct {
     Size        int64     `json:"size"`         // File size in bytes
     Modified    time.Time `json:"modified"`     // Last modified date
     IsDownloads bool      `json:"is_download"`  // True if currently downloading
+
+    // Read from the GGUF header
+    Architecture   string `json:"architecture"`    // e.g. "bitnet", "llama"
+    ParameterCount uint64 `json:"parameter_count"` // total number of weights
+    Quantization   string `json:"quantization"`    // e.g. "I2_S", "Q4_K_M"
+    ContextLength  uint64 `json:"context_length"`  // training context size in tokens
+    VocabSize      uint64 `json:"vocab_size"`      // tokenizer vocabulary size
+
+    // Set for models defined by a Modelfile; the other fields describe its base
+    Modelfile *Modelfile `json:"modelfile,omitempty"`
 }
 
 // The DownloadStatus struct has been removed from this file to resolve the "redeclared" error.
@@ -0,0 +1,300 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+)
+
+// ModelfileExt is the extension of derived model definitions in the models directory
+const ModelfileExt = ".modelfile"
+
+// ErrInvalidModelfile is returned for a Modelfile that cannot be parsed
+var ErrInvalidModelfile = errors.New("invalid Modelfile")
+
+// ErrInvalidModelName is returned by Create for names that cannot be a model ID
+var ErrInvalidModelName = errors.New("invalid model name")
+
+// Modelfile defines a model on top of a GGUF file, like Ollama's Modelfile:
+//
+//	FROM ggml-model-i2_s.gguf
+//	SYSTEM """You are a terse assistant."""
+//	TEMPLATE chatml
+//	PARAMETER temperature 0.3
+//	PARAMETER stop "<|im_end|>"
+type Modelfile struct {
+	From   string        `json:"from"`       // base model: ID of an installed GGUF model or a path to one
+	Params config.Params `json:"parameters"` // SYSTEM, TEMPLATE and PARAMETER settings
+}
+
+// modelfileParams are the settings PARAMETER accepts, with Ollama's names
+// for them mapped to ours
+var modelfileParams = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "top_p",
+	"top_k":             "top_k",
+	"min_p":             "min_p",
+	"typical_p":         "typical_p",
+	"repeat_penalty":    "repeat_penalty",
+	"presence_penalty":  "presence_penalty",
+	"frequency_penalty": "frequency_penalty",
+	"mirostat":          "mirostat",
+	"mirostat_tau":      "mirostat_tau",
+	"mirostat_eta":      "mirostat_eta",
+	"seed":              "seed",
+	"stop":              "stop",
+	"max_tokens":        "max_tokens",
+	"num_predict":       "max_tokens",
+	"threads":           "threads",
+	"num_thread":        "threads",
+	"ctx_size":          "ctx_size",
+	"num_ctx":           "ctx_size",
+	"batch_size":        "batch_size",
+	"num_batch":         "batch_size",
+	"mlock":             "mlock",
+	"use_mlock":         "mlock",
+	"no_mmap":           "no_mmap",
+}
+
+// ParseModelfile reads a Modelfile. Instructions are case-insensitive, lines
+// starting with # are comments, and SYSTEM text may span lines inside """.
+func ParseModelfile(r io.Reader) (*Modelfile, error) {
+	mf := &Modelfile{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	fail := func(format string, args ...any) (*Modelfile, error) {
+		return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidModelfile, line, fmt.Sprintf(format, args...))
+	}
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		instruction, args, _ := strings.Cut(text, " ")
+		args = strings.TrimSpace(args)
+
+		// """ quotes text over several lines
+		if rest, ok := strings.CutPrefix(args, `"""`); ok {
+			start := line
+			for !strings.HasSuffix(rest, `"""`) {
+				if !scanner.Scan() {
+					line = start
+					return fail(`unterminated """`)
+				}
+				line++
+				rest += "\n" + strings.TrimRight(scanner.Text(), " \t\r")
+			}
+			args = strings.TrimSpace(strings.TrimSuffix(rest, `"""`))
+		} else {
+			args = unquote(args)
+		}
+
+		switch strings.ToUpper(instruction) {
+		case "FROM":
+			if mf.From != "" {
+				return fail("more than one FROM")
+			}
+			mf.From = args
+		case "SYSTEM":
+			mf.Params.SystemPrompt = args
+		case "TEMPLATE":
+			mf.Params.Template = args
+		case "PARAMETER":
+			if err := mf.setParam(args); err != nil {
+				return fail("%v", err)
+			}
+		case "ADAPTER", "LICENSE", "MESSAGE":
+			return fail("%s is not supported", strings.ToUpper(instruction))
+		default:
+			return fail("unknown instruction %q", instruction)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if mf.From == "" {
+		return nil, fmt.Errorf("%w: no FROM instruction", ErrInvalidModelfile)
+	}
+	return mf, nil
+}
+
+// setParam applies a "name value" PARAMETER line
+func (mf *Modelfile) setParam(args string) error {
+	name, value, _ := strings.Cut(args, " ")
+	key, ok := modelfileParams[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	value = unquote(strings.TrimSpace(value))
+	if value == "" {
+		return fmt.Errorf("parameter %s has no value", name)
+	}
+	if key == "stop" {
+		mf.Params.Stop = append(mf.Params.Stop, value)
+		return nil
+	}
+
+	// Decoding through the JSON names checks the value's type
+	raw, err := json.Marshal(map[string]json.RawMessage{key: json.RawMessage(value)})
+	if err != nil || json.Unmarshal(raw, &mf.Params) != nil {
+		return fmt.Errorf("invalid value %q for %s", value, name)
+	}
+	return nil
+}
+
+// String formats the Modelfile the way Create stores it
+func (mf *Modelfile) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FROM %s\n", mf.From)
+	if mf.Params.Template != "" {
+		fmt.Fprintf(&b, "TEMPLATE %s\n", mf.Params.Template)
+	}
+	if mf.Params.SystemPrompt != "" {
+		fmt.Fprintf(&b, "SYSTEM \"\"\"%s\"\"\"\n", mf.Params.SystemPrompt)
+	}
+
+	// The other settings, in the order of their names
+	var fields map[string]json.RawMessage
+	data, _ := json.Marshal(mf.Params)
+	json.Unmarshal(data, &fields)
+	delete(fields, "system_prompt")
+	delete(fields, "template")
+	delete(fields, "stop")
+	delete(fields, "logit_bias")
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "PARAMETER %s %s\n", name, fields[name])
+	}
+	for _, stop := range mf.Params.Stop {
+		fmt.Fprintf(&b, "PARAMETER stop %s\n", strconv.Quote(stop))
+	}
+	return b.String()
+}
+
+// unquote removes the double quotes around a value, if it has them
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	return s
+}
+
+var modelNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Create registers a model defined by mf under name, replacing an earlier
+// definition of that name. The base model must be an installed GGUF model
+// or a path to one.
+func (m *Manager) Create(name string, mf *Modelfile) (ModelInfo, error) {
+	if !modelNamePattern.MatchString(name) || strings.HasSuffix(strings.ToLower(name), ".gguf") {
+		return ModelInfo{}, fmt.Errorf("%w: %q (use letters, digits, '.', '_' and '-', without .gguf)", ErrInvalidModelName, name)
+	}
+
+	base, err := m.Resolve(mf.From)
+	if err != nil {
+		return ModelInfo{}, fmt.Errorf("base model: %w", err)
+	}
+	if base.Modelfile != nil {
+		return ModelInfo{}, fmt.Errorf("%w: FROM %s is itself defined by a Modelfile", ErrInvalidModelfile, mf.From)
+	}
+	stored := *mf
+	stored.From = base.ID
+	if _, err := m.Find(base.ID); err != nil {
+		// Not in the models directory, so keep where it is
+		stored.From = base.FilePath
+	}
+
+	dir, err := modelsDir()
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	m.mu.Lock()
+	err = writeFileAtomic(filepath.Join(dir, name+ModelfileExt), []byte(stored.String()))
+	m.mu.Unlock()
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	return m.Find(name)
+}
+
+// scanModelfiles returns the models defined by Modelfiles in dir. Their base
+// is looked up among installed, or read from the path FROM names.
+func scanModelfiles(dir string, entries []os.DirEntry, installed []ModelInfo) []ModelInfo {
+	var derived []ModelInfo
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ModelfileExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		mf, err := ParseModelfile(bytes.NewReader(data))
+		if err != nil {
+			log.Printf("Skipping %s: %v", entry.Name(), err)
+			continue
+		}
+		base, err := findBase(mf.From, installed)
+		if err != nil {
+			log.Printf("Skipping %s: base model: %v", entry.Name(), err)
+			continue
+		}
+
+		info := base
+		info.ID = name
+		info.Name = name
+		info.Modelfile = mf
+		if stat, err := entry.Info(); err == nil {
+			info.Modified = stat.ModTime()
+		}
+		derived = append(derived, info)
+	}
+	return derived
+}
+
+func findBase(from string, installed []ModelInfo) (ModelInfo, error) {
+	for _, info := range installed {
+		if info.ID == from || info.Filename == from {
+			return info, nil
+		}
+	}
+	stat, err := os.Stat(from)
+	if err != nil {
+		return ModelInfo{}, fmt.Errorf("%w: %s", ErrModelNotFound, from)
+	}
+	return inspectModel(from, stat)
+}
+
+// writeFileAtomic replaces path with data without leaving a partial file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
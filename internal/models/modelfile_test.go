@@ -0,0 +1,64 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseModelfile(t *testing.T) {
+	mf, err := ParseModelfile(strings.NewReader(`# A terse assistant
+FROM ggml-model-i2_s.gguf
+system """
+You are terse.
+Answer in one line.
+"""
+TEMPLATE chatml
+PARAMETER temperature 0.3
+PARAMETER num_ctx 4096
+PARAMETER use_mlock true
+PARAMETER stop "<|im_end|>"
+PARAMETER stop User:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := mf.Params
+	if mf.From != "ggml-model-i2_s.gguf" || p.SystemPrompt != "You are terse.\nAnswer in one line." || p.Template != "chatml" {
+		t.Errorf("modelfile = %+v", mf)
+	}
+	if *p.Temperature != 0.3 || *p.ContextSize != 4096 || !*p.MLock || strings.Join(p.Stop, ",") != "<|im_end|>,User:" {
+		t.Errorf("params = %+v", p)
+	}
+
+	// String writes a Modelfile that parses to the same definition
+	again, err := ParseModelfile(strings.NewReader(mf.String()))
+	if err != nil {
+		t.Fatalf("%v\n%s", err, mf)
+	}
+	if again.String() != mf.String() {
+		t.Errorf("round trip:\n%s\nwant:\n%s", again, mf)
+	}
+}
+
+func TestParseModelfileErrors(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+	}{
+		{"no FROM", "SYSTEM hi", "no FROM"},
+		{"two FROMs", "FROM a.gguf\nFROM b.gguf", "line 2: more than one FROM"},
+		{"unknown instruction", "FROM a.gguf\nQUANTIZE q4", `line 2: unknown instruction "QUANTIZE"`},
+		{"adapter", "FROM a.gguf\nADAPTER lora.gguf", "ADAPTER is not supported"},
+		{"unknown parameter", "FROM a.gguf\nPARAMETER warmth 1", `unknown parameter "warmth"`},
+		{"wrong type", "FROM a.gguf\nPARAMETER top_k 0.5", `invalid value "0.5" for top_k`},
+		{"unterminated", "FROM a.gguf\nSYSTEM \"\"\"hi\nthere", `line 2: unterminated """`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseModelfile(strings.NewReader(tt.text))
+			if !errors.Is(err, ErrInvalidModelfile) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Package modeltest writes small GGUF files for tests. They carry metadata
+// only and no tensors, which is enough for the scanner, the chat template
+// detection and a fake engine.
+package modeltest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/utils"
+)
+
+// GGUF metadata value types used here
+const (
+	typeUint32 uint32 = 4
+	typeString uint32 = 8
+	typeUint64 uint32 = 10
+)
+
+// WriteGGUF creates a GGUF v3 file at path with the given metadata. Values
+// may be strings, uint32 or uint64.
+func WriteGGUF(t testing.TB, path string, metadata map[string]any) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	write := func(v any) { binary.Write(&buf, binary.LittleEndian, v) }
+	writeString := func(s string) {
+		write(uint64(len(s)))
+		buf.WriteString(s)
+	}
+
+	write(uint32(0x46554747)) // "GGUF"
+	write(uint32(3))
+	write(uint64(0)) // tensors
+	write(uint64(len(metadata)))
+
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeString(k)
+		switch v := metadata[k].(type) {
+		case string:
+			write(typeString)
+			writeString(v)
+		case uint32:
+			write(typeUint32)
+			write(v)
+		case uint64:
+			write(typeUint64)
+			write(v)
+		default:
+			t.Fatalf("modeltest: unsupported metadata type %T for %s", v, k)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Home points the user's home directory at a fresh temporary directory, so
+// models, logs and config.json are isolated from the real ones, and returns
+// the models directory inside it
+func Home(t testing.TB) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	dir := filepath.Join(home, "."+utils.AppName, "models")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -2,28 +2,25 @@ package models
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mibrahimzia/bitnet-runner/internal/utils"
 )
 
-// ScanModels looks for .gguf files in the models directory
+// ScanModels looks for .gguf files in the models directory, followed by the
+// models Modelfiles there define
 func ScanModels() ([]ModelInfo, error) {
-	// 1. Get the data directory
-	appDir, err := utils.GetAppDataDir()
+	// 1. Get the models directory
+	modelsDir, err := modelsDir()
 	if err != nil {
 		return nil, err
 	}
 
-	modelsDir := filepath.Join(appDir, "models")
-	
-	// Ensure directory exists, create if not
-	if err := os.MkdirAll(modelsDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create models dir: %w", err)
-	}
-
 	var models []ModelInfo
 
 	// 2. Read directory
@@ -49,17 +46,82 @@ func ScanModels() ([]ModelInfo, error) {
 
 		fullPath := filepath.Join(modelsDir, entry.Name())
 
-		models = append(models, ModelInfo{
-			ID:       entry.Name(),
-			Name:     cleanName(entry.Name()),
-			Filename: entry.Name(),
-			FilePath: fullPath,
-			Size:     info.Size(),
-			Modified: info.ModTime(),
-		})
+		// 4. Read the GGUF header, rejecting files that are not valid models
+		model, err := inspectModel(fullPath, info)
+		if err != nil {
+			log.Printf("Skipping %s: %v", entry.Name(), err)
+			continue
+		}
+		models = append(models, model)
+	}
+
+	return append(models, scanModelfiles(modelsDir, entries, models)...), nil
+}
+
+// modelsDir returns the models directory, creating it if needed
+func modelsDir() (string, error) {
+	appDir, err := utils.GetAppDataDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(appDir, "models")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create models dir: %w", err)
+	}
+	return dir, nil
+}
+
+// inspectModel builds the ModelInfo for a GGUF file from its header
+func inspectModel(path string, info os.FileInfo) (ModelInfo, error) {
+	header, err := readHeader(path, info)
+	if err != nil {
+		return ModelInfo{}, err
 	}
 
-	return models, nil
+	return ModelInfo{
+		ID:             info.Name(),
+		Name:           cleanName(info.Name()),
+		Filename:       info.Name(),
+		FilePath:       path,
+		Size:           info.Size(),
+		Modified:       info.ModTime(),
+		Architecture:   header.Architecture(),
+		ParameterCount: header.ParameterCount(),
+		Quantization:   header.Quantization(),
+		ContextLength:  header.ContextLength(),
+		VocabSize:      header.VocabSize(),
+	}, nil
+}
+
+// headerCache avoids re-parsing GGUF headers on every scan.
+// Entries are keyed by path and invalidated when size or mtime change.
+var (
+	headerCacheMu sync.Mutex
+	headerCache   = make(map[string]cachedHeader)
+)
+
+type cachedHeader struct {
+	size    int64
+	modTime time.Time
+	file    *GGUFFile
+}
+
+func readHeader(path string, info os.FileInfo) (*GGUFFile, error) {
+	headerCacheMu.Lock()
+	defer headerCacheMu.Unlock()
+
+	if c, ok := headerCache[path]; ok && c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+		return c.file, nil
+	}
+
+	file, err := ParseGGUF(path)
+	if err != nil {
+		delete(headerCache, path)
+		return nil, err
+	}
+	headerCache[path] = cachedHeader{size: info.Size(), modTime: info.ModTime(), file: file}
+	return file, nil
 }
 
 // cleanName removes extension and makes it readable
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/pkg/api"
+)
+
+// HandleOpenAICompletion implements POST /v1/completions.
+// The prompt is sent to the engine verbatim, without any chat template.
+func (s *Server) HandleOpenAICompletion(c *gin.Context) {
+	var req api.OpenAICompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	model, err := s.resolveModel(req.Model)
+	if err != nil {
+		openAIError(c, modelErrorStatus(err), "invalid_request_error", err.Error())
+		return
+	}
+
+	cfg, err := s.baseConfig(model, "")
+	if err != nil {
+		openAIError(c, engineErrorStatus(err), "invalid_request_error", err.Error())
+		return
+	}
+	cfg.Prompt = string(req.Prompt)
+	cfg.Raw = true
+	applyOpenAISampling(&cfg, req.Temperature, req.TopP, req.MaxTokens, req.Seed, req.Stop)
+	if err := applyOpenAIPenalties(&cfg, req.PresencePenalty, req.FrequencyPenalty, req.LogitBias); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	id := newCompletionID("cmpl")
+	tagRequest(c, &cfg, id)
+
+	stream, err := s.executor.StartInference(c.Request.Context(), cfg)
+	if err != nil {
+		openAIError(c, engineErrorStatus(err), "server_error", err.Error())
+		return
+	}
+
+	created := time.Now().Unix()
+	usage := func(done engine.Completion) *api.OpenAIUsage {
+		return s.openAIUsage(cfg.ModelPath, cfg.Prompt, done)
+	}
+
+	if req.Stream {
+		includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+		s.streamOpenAICompletion(c, stream, id, created, req.Model, includeUsage, usage)
+		return
+	}
+
+	text, done, err := collectStream(stream)
+	if err != nil {
+		openAIError(c, engineErrorStatus(err), "server_error", err.Error())
+		return
+	}
+	reason := finishReason(done)
+	c.JSON(http.StatusOK, api.OpenAICompletionResponse{
+		ID:      id,
+		Object:  "text_completion",
+		Created: created,
+		Model:   req.Model,
+		Choices: []api.OpenAICompletionChoice{{Text: text, Index: 0, FinishReason: &reason}},
+		Usage:   usage(done),
+	})
+}
+
+// streamOpenAICompletion writes text completion chunks as Server-Sent Events
+func (s *Server) streamOpenAICompletion(c *gin.Context, stream <-chan engine.StreamEvent, id string, created int64, model string, includeUsage bool, usage func(engine.Completion) *api.OpenAIUsage) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	chunk := func(choices []api.OpenAICompletionChoice) api.OpenAICompletionResponse {
+		return api.OpenAICompletionResponse{
+			ID:      id,
+			Object:  "text_completion",
+			Created: created,
+			Model:   model,
+			Choices: choices,
+		}
+	}
+
+	disconnected := c.Request.Context().Done()
+	for {
+		select {
+		case <-disconnected:
+			return
+		case ev, ok := <-stream:
+			if !ok {
+				return
+			}
+			switch ev.Type {
+			case engine.StreamToken:
+				if err := writeSSE(c, chunk([]api.OpenAICompletionChoice{{Text: ev.Token, Index: 0}})); err != nil {
+					return
+				}
+			case engine.StreamError:
+				writeSSEError(c, ev.Err)
+				return
+			case engine.StreamDone:
+				reason := finishReason(*ev.Completion)
+				writeSSE(c, chunk([]api.OpenAICompletionChoice{{Index: 0, FinishReason: &reason}}))
+
+				// With include_usage, OpenAI sends one extra chunk with no choices
+				if includeUsage {
+					final := chunk([]api.OpenAICompletionChoice{})
+					final.Usage = usage(*ev.Completion)
+					writeSSE(c, final)
+				}
+				fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+				c.Writer.Flush()
+				return
+			}
+		}
+	}
+}
+
+// openAIUsage reports the token counts of a finished generation. Backends
+// that do not report the prompt size leave it to the tokenizer, run on the
+// given prompt text.
+func (s *Server) openAIUsage(modelPath, prompt string, done engine.Completion) *api.OpenAIUsage {
+	promptTokens := done.TokensEvaluated
+	if promptTokens == 0 {
+		if tokens, err := s.executor.Tokenize(modelPath, prompt); err == nil {
+			promptTokens = len(tokens)
+		}
+	}
+	return &api.OpenAIUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: done.TokensPredicted,
+		TotalTokens:      promptTokens + done.TokensPredicted,
+	}
+}
@@ -1,11 +1,15 @@
 package server
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"sync"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
 	"github.com/mibrahimzia/bitnet-runner/internal/engine"
-	//"github.com/mibrahimzia/bitnet-runner/internal/models"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
 	"github.com/mibrahimzia/bitnet-runner/pkg/api"
 )
 
@@ -19,6 +23,210 @@ func (s *Server) HandleListModels(c *gin.Context) {
 	c.JSON(http.StatusOK, models)
 }
 
+// HandleShowModel returns the full metadata and tensor table of one model
+func (s *Server) HandleShowModel(c *gin.Context) {
+	details, err := s.modelManager.Show(c.Param("id"))
+	if err != nil {
+		c.JSON(modelErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, details)
+}
+
+// EngineStatus reports the engine binary in use and what it is running
+type EngineStatus struct {
+	Engine  engine.BinaryInfo    `json:"engine"`
+	Models  []engine.LoadedModel `json:"models"`
+	Queues  []engine.QueueStats  `json:"queues"`
+	Crashes int                  `json:"crashes"`
+}
+
+// HandleEngineStatus reports which engine binary is in use and its loaded models
+func (s *Server) HandleEngineStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, EngineStatus{
+		Engine:  s.engineInfo,
+		Models:  s.executor.Loaded(),
+		Queues:  s.executor.Queues(),
+		Crashes: len(s.executor.Crashes()),
+	})
+}
+
+// HandleLoadedModels lists the models the engine currently keeps loaded
+func (s *Server) HandleLoadedModels(c *gin.Context) {
+	c.JSON(http.StatusOK, s.executor.Loaded())
+}
+
+// HandleEngineQueue reports the request queue of each model: slots, waiting
+// requests and wait times
+func (s *Server) HandleEngineQueue(c *gin.Context) {
+	c.JSON(http.StatusOK, s.executor.Queues())
+}
+
+// HandleEngineCrashes lists recent engine crashes with their reasons
+func (s *Server) HandleEngineCrashes(c *gin.Context) {
+	c.JSON(http.StatusOK, s.executor.Crashes())
+}
+
+// HandleEngineEvents streams model lifecycle events (loading, ready,
+// crashed, restarting, failed) as Server-Sent Events
+func (s *Server) HandleEngineEvents(c *gin.Context) {
+	events, unsubscribe := s.executor.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev := <-events:
+			if err := writeSSE(c, ev); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// HandleEngineLogs returns the recent engine output. With ?follow=true the
+// tail is followed by new lines as Server-Sent Events; ?lines limits the
+// tail (default 200, 0 = all that are kept) and ?model filters by model
+// file name.
+func (s *Server) HandleEngineLogs(c *gin.Context) {
+	lines, err := strconv.Atoi(c.DefaultQuery("lines", "200"))
+	if err != nil || lines < 0 {
+		c.JSON(http.StatusBadRequest, api.ErrorResponse{Error: "lines must be a number of at least 0"})
+		return
+	}
+	// The store keeps no more than that anyway
+	if lines == 0 || lines > engine.MaxLogLines {
+		lines = engine.MaxLogLines
+	}
+	model := c.Query("model")
+	match := func(l engine.LogLine) bool {
+		return model == "" || l.Model == model
+	}
+
+	logs := s.executor.Logs()
+	tail := make([]engine.LogLine, 0, lines)
+	for _, l := range logs.Tail(0) {
+		if match(l) {
+			tail = append(tail, l)
+		}
+	}
+	if len(tail) > lines {
+		tail = tail[len(tail)-lines:]
+	}
+
+	if c.Query("follow") != "true" {
+		c.JSON(http.StatusOK, tail)
+		return
+	}
+
+	follow, stop := logs.Follow()
+	defer stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	for _, l := range tail {
+		if err := writeSSE(c, l); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case l := <-follow:
+			if !match(l) {
+				continue
+			}
+			if err := writeSSE(c, l); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Headers naming a generation and ranking it in the queue
+const (
+	requestIDHeader = "X-Request-Id" // the ID a generation can be cancelled with
+	priorityHeader  = "X-Priority"   // higher runs first when serving with --queue-order priority
+)
+
+// tagRequest names the generation after id, announces the ID in the response
+// headers and applies the client's priority
+func tagRequest(c *gin.Context, cfg *engine.InferenceConfig, id string) {
+	cfg.RequestID = id
+	c.Header(requestIDHeader, id)
+	if p, err := strconv.Atoi(c.GetHeader(priorityHeader)); err == nil {
+		cfg.Priority = p
+	}
+}
+
+// HandleRequests lists the generations in flight
+func (s *Server) HandleRequests(c *gin.Context) {
+	c.JSON(http.StatusOK, s.executor.Requests())
+}
+
+// HandleCancelRequest stops one generation by the ID from its X-Request-Id header
+func (s *Server) HandleCancelRequest(c *gin.Context) {
+	if err := s.executor.Cancel(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// HandleTokenize converts text to token IDs with a model's tokenizer
+func (s *Server) HandleTokenize(c *gin.Context) {
+	var req api.TokenizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.ErrorResponse{Error: "Invalid JSON"})
+		return
+	}
+	modelPath, err := s.resolveModelPath(req.Model)
+	if err != nil {
+		c.JSON(modelErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tokens, err := s.executor.Tokenize(modelPath, req.Content)
+	if err != nil {
+		c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, api.TokenizeResponse{Tokens: tokens})
+}
+
+// HandleEmbed returns the embedding vector of a text
+func (s *Server) HandleEmbed(c *gin.Context) {
+	var req api.EmbedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.ErrorResponse{Error: "Invalid JSON"})
+		return
+	}
+	modelPath, err := s.resolveModelPath(req.Model)
+	if err != nil {
+		c.JSON(modelErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	embedding, err := s.executor.Embed(modelPath, req.Content)
+	if err != nil {
+		c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, api.EmbedResponse{Embedding: embedding})
+}
+
 // HandlePullModel triggers a background download
 func (s *Server) HandlePullModel(c *gin.Context) {
 	var req api.ModelDownloadRequest
@@ -27,17 +235,40 @@ func (s *Server) HandlePullModel(c *gin.Context) {
 		return
 	}
 
-	// Start download (non-blocking)
+	// Url may also be a Hugging Face reference; Name defaults to its file name
+	remote, err := s.resolveRemote(c, req.Url, req.Name)
+	if err != nil {
+		c.JSON(downloadErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	req.Name = remote.Filename
+	if req.SHA256 != "" {
+		remote.SHA256 = req.SHA256
+	}
+
+	// Start download (non-blocking). It outlives the request, so it does not
+	// use the request context.
 	// Note: In a full implementation, you'd store the channel in a map to track progress via another endpoint
-	_, err := s.modelManager.Download(req.Url, req.Name)
+	progress, err := s.modelManager.Download(context.Background(), remote.URL, req.Name, models.DownloadOptions{SHA256: remote.SHA256})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, api.ErrorResponse{Error: err.Error()})
+		c.JSON(downloadErrorStatus(err), api.ErrorResponse{Error: err.Error()})
 		return
 	}
+	go drain(progress)
 
 	c.JSON(http.StatusAccepted, gin.H{"status": "download_started", "model": req.Name})
 }
 
+// HandlePresets lists the generation presets, built-in and from the config file
+func (s *Server) HandlePresets(c *gin.Context) {
+	file, err := config.Load()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, engine.Presets(file))
+}
+
 // HandleChatStream manages the WebSocket connection and Engine execution
 func (s *Server) HandleChatStream(c *gin.Context) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -48,45 +279,126 @@ func (s *Server) HandleChatStream(c *gin.Context) {
 
 	var req api.ChatRequest
 	if err := conn.ReadJSON(&req); err != nil {
-		return 
+		return
+	}
+
+	model, err := s.resolveModel(req.Model)
+	if err != nil {
+		conn.WriteJSON(api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Queue positions arrive from other requests' goroutines, so writes are serialized
+	var writeMu sync.Mutex
+	write := func(v any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(v)
+	}
+
+	// Prepare Engine Config: request settings over the model's and the preset's
+	cfg, err := s.baseConfig(model, req.Preset)
+	if err != nil {
+		write(api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	cfg.Apply(chatParams(req))
+	cfg.Prompt = req.Prompt
+	cfg.Priority = req.Priority
+	cfg.RequestID = engine.NewRequestID()
+	cfg.OnQueued = func(position int) {
+		write(api.ChatResponse{QueuePosition: position})
 	}
 
-	// Prepare Engine Config
-	cfg := engine.InferenceConfig{
-		ModelPath:     req.Model, // This should be the full path resolved by manager
-		Prompt:        req.Prompt,
-		SystemPrompt:  req.System,
-		Temperature:   req.Temperature,
-		TopP:          req.TopP,
-		TopK:          req.TopK,
-		MaxTokens:     req.MaxTokens,
-		Threads:       4, // Default
+	for _, m := range req.Messages {
+		cfg.Messages = append(cfg.Messages, engine.Message{Role: m.Role, Content: m.Content})
 	}
 
-	// Initialize Executor
-	// Note: We resolve the binary path dynamically
-	binPath, _ := s.getBinaryPath() 
-	exec := engine.NewExecutor(binPath)
+	// The executor is shared so every client reuses the loaded model
+	exec := s.executor
+
+	// Closing the socket cancels this client's generation only
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	// The ID comes first so the client can cancel the request while it is queued
+	if err := write(api.ChatResponse{RequestID: cfg.RequestID}); err != nil {
+		return
+	}
 
 	// Start Inference
-	stream, err := exec.StartInference(cfg)
+	stream, err := exec.StartInference(ctx, cfg)
 	if err != nil {
-		conn.WriteJSON(api.ErrorResponse{Error: err.Error()})
+		write(api.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	// Stream loop
-	for token := range stream {
-		resp := api.ChatResponse{
-			Content: token,
-			Done:    false,
+	// Stream loop; the last event says how generation ended
+	for ev := range stream {
+		var resp api.ChatResponse
+		switch ev.Type {
+		case engine.StreamToken:
+			resp.Content = ev.Token
+		case engine.StreamError:
+			resp = api.ChatResponse{Done: true, Error: ev.Err.Error()}
+		case engine.StreamDone:
+			resp = doneResponse(*ev.Completion)
 		}
-		if err := conn.WriteJSON(resp); err != nil {
-			exec.Stop()
-			break
+		if err := write(resp); err != nil {
+			cancel()
 		}
 	}
+}
+
+// chatParams collects the settings a WebSocket chat request sets
+func chatParams(req api.ChatRequest) config.Params {
+	return config.Params{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		TopK:             req.TopK,
+		MinP:             req.MinP,
+		TypicalP:         req.TypicalP,
+		RepeatPenalty:    req.RepeatPenalty,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		Mirostat:         req.Mirostat,
+		MirostatTau:      req.MirostatTau,
+		MirostatEta:      req.MirostatEta,
+		LogitBias:        req.LogitBias,
+		MaxTokens:        req.MaxTokens,
+		Seed:             req.Seed,
+		Stop:             req.Stop,
+		SystemPrompt:     req.System,
+		Template:         req.Template,
+
+		Threads:     req.Threads,
+		ContextSize: req.ContextSize,
+		BatchSize:   req.BatchSize,
+		MLock:       req.MLock,
+		NoMMap:      req.NoMMap,
+	}
+}
 
-	// Send done signal
-	conn.WriteJSON(api.ChatResponse{Done: true})
-}
\ No newline at end of file
+// doneResponse is the final WebSocket message of a finished generation
+func doneResponse(c engine.Completion) api.ChatResponse {
+	resp := api.ChatResponse{
+		Done:            true,
+		StopReason:      c.StopReason,
+		StopWord:        c.StopWord,
+		TokensEvaluated: c.TokensEvaluated,
+		TokensPredicted: c.TokensPredicted,
+	}
+	if c.Timings != (engine.Timings{}) {
+		t := api.Timings(c.Timings)
+		resp.Timings = &t
+	}
+	return resp
+}
@@ -0,0 +1,427 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+	"github.com/mibrahimzia/bitnet-runner/pkg/api"
+)
+
+// HandleOllamaTags implements GET /api/tags
+func (s *Server) HandleOllamaTags(c *gin.Context) {
+	list, err := s.modelManager.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tags := make([]api.OllamaModel, 0, len(list))
+	for _, m := range list {
+		tags = append(tags, api.OllamaModel{
+			Name:       m.ID,
+			Model:      m.ID,
+			ModifiedAt: m.Modified,
+			Size:       m.Size,
+			Details: api.OllamaModelDetails{
+				Format:            "gguf",
+				Family:            m.Architecture,
+				ParameterSize:     models.FormatParameterCount(m.ParameterCount),
+				QuantizationLevel: m.Quantization,
+			},
+		})
+	}
+	c.JSON(http.StatusOK, api.OllamaTagsResponse{Models: tags})
+}
+
+// HandleOllamaPull implements POST /api/pull, streaming download progress as NDJSON
+func (s *Server) HandleOllamaPull(c *gin.Context) {
+	var req api.OllamaPullRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if req.Model == "" {
+		req.Model = req.Name
+	}
+
+	// A direct link or a Hugging Face reference such as hf.co/org/repo:quant
+	remote, err := s.resolveRemote(c, req.Model, "")
+	if err != nil {
+		c.JSON(downloadErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	name := remote.Filename
+	if req.SHA256 != "" {
+		remote.SHA256 = req.SHA256
+	}
+
+	// Disconnecting cancels the download and removes the partial file
+	progress, err := s.modelManager.Download(c.Request.Context(), remote.URL, name, models.DownloadOptions{SHA256: remote.SHA256})
+	if err != nil {
+		c.JSON(downloadErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	defer func() { go drain(progress) }()
+
+	if !streamRequested(req.Stream) {
+		completed := false
+		for status := range progress {
+			if status.Error != "" {
+				c.JSON(http.StatusInternalServerError, api.ErrorResponse{Error: status.Error})
+				return
+			}
+			completed = completed || status.IsCompleted
+		}
+		// Progress may also end without a result, e.g. when the download is cancelled
+		if !completed {
+			c.JSON(http.StatusInternalServerError, api.ErrorResponse{Error: "download of " + name + " did not complete"})
+			return
+		}
+		c.JSON(http.StatusOK, api.OllamaProgressResponse{Status: "success"})
+		return
+	}
+
+	startNDJSON(c)
+	writeNDJSON(c, api.OllamaProgressResponse{Status: "pulling manifest"})
+	for status := range progress {
+		switch {
+		case status.Error != "":
+			writeNDJSON(c, api.ErrorResponse{Error: status.Error})
+			return
+		case status.IsCompleted:
+			writeNDJSON(c, api.OllamaProgressResponse{Status: "success"})
+			return
+		default:
+			writeNDJSON(c, api.OllamaProgressResponse{
+				Status:    "pulling " + name,
+				Total:     status.TotalBytes,
+				Completed: status.Downloaded,
+			})
+		}
+	}
+}
+
+// resolveRemote finds the file a pull request names, looking Hugging Face
+// references up on the endpoint from the config file
+func (s *Server) resolveRemote(c *gin.Context, ref, name string) (models.RemoteFile, error) {
+	file, err := config.Load()
+	if err != nil {
+		return models.RemoteFile{}, err
+	}
+	return models.NewHFResolver(file).Resolve(c.Request.Context(), ref, name)
+}
+
+// drain discards the rest of a download's progress so the download can finish
+func drain(progress <-chan models.DownloadStatus) {
+	for range progress {
+	}
+}
+
+// HandleOllamaGenerate implements POST /api/generate
+func (s *Server) HandleOllamaGenerate(c *gin.Context) {
+	var req api.OllamaGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	model, err := s.resolveModel(req.Model)
+	if err != nil {
+		c.JSON(modelErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// An empty prompt only loads the model, as Ollama does
+	if req.Prompt == "" {
+		if err := s.executor.LoadModel(model.FilePath); err != nil {
+			c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, api.OllamaGenerateResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Done: true, DoneReason: "load"})
+		return
+	}
+
+	cfg, err := s.baseConfig(model, "")
+	if err != nil {
+		c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	cfg.Prompt = req.Prompt
+	if req.System != "" {
+		cfg.SystemPrompt = req.System
+	}
+	cfg.Raw = req.Raw
+	applyOllamaOptions(&cfg, req.Options)
+
+	tagRequest(c, &cfg, engine.NewRequestID())
+
+	start := time.Now()
+	stream, err := s.executor.StartInference(c.Request.Context(), cfg)
+	if err != nil {
+		c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	final := func(text string, done engine.Completion) api.OllamaGenerateResponse {
+		return api.OllamaGenerateResponse{
+			Model:              req.Model,
+			CreatedAt:          time.Now().UTC(),
+			Response:           text,
+			Done:               true,
+			DoneReason:         finishReason(done),
+			TotalDuration:      time.Since(start).Nanoseconds(),
+			PromptEvalCount:    done.TokensEvaluated,
+			PromptEvalDuration: msToNanos(done.Timings.PromptMs),
+			EvalCount:          done.TokensPredicted,
+			EvalDuration:       msToNanos(done.Timings.PredictedMs),
+		}
+	}
+
+	if !streamRequested(req.Stream) {
+		text, done, err := collectStream(stream)
+		if err != nil {
+			c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, final(text, done))
+		return
+	}
+
+	startNDJSON(c)
+	last, ok := s.pipeNDJSON(c, stream, func(token string) any {
+		return api.OllamaGenerateResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Response: token}
+	})
+	switch {
+	case !ok:
+	case last.Type == engine.StreamError:
+		writeNDJSON(c, api.OllamaGenerateResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Error: last.Err.Error()})
+	default:
+		writeNDJSON(c, final("", *last.Completion))
+	}
+}
+
+// HandleOllamaChat implements POST /api/chat
+func (s *Server) HandleOllamaChat(c *gin.Context) {
+	var req api.OllamaChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	model, err := s.resolveModel(req.Model)
+	if err != nil {
+		c.JSON(modelErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// No messages only loads the model, as Ollama does
+	if len(req.Messages) == 0 {
+		if err := s.executor.LoadModel(model.FilePath); err != nil {
+			c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, api.OllamaChatResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Done: true, DoneReason: "load"})
+		return
+	}
+
+	cfg, err := s.baseConfig(model, "")
+	if err != nil {
+		c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+	applyOllamaOptions(&cfg, req.Options)
+
+	for _, m := range req.Messages {
+		cfg.Messages = append(cfg.Messages, engine.Message{Role: m.Role, Content: m.Content})
+	}
+
+	tagRequest(c, &cfg, engine.NewRequestID())
+
+	start := time.Now()
+	stream, err := s.executor.StartInference(c.Request.Context(), cfg)
+	if err != nil {
+		c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	final := func(text string, done engine.Completion) api.OllamaChatResponse {
+		return api.OllamaChatResponse{
+			Model:              req.Model,
+			CreatedAt:          time.Now().UTC(),
+			Message:            &api.OllamaMessage{Role: "assistant", Content: text},
+			Done:               true,
+			DoneReason:         finishReason(done),
+			TotalDuration:      time.Since(start).Nanoseconds(),
+			PromptEvalCount:    done.TokensEvaluated,
+			PromptEvalDuration: msToNanos(done.Timings.PromptMs),
+			EvalCount:          done.TokensPredicted,
+			EvalDuration:       msToNanos(done.Timings.PredictedMs),
+		}
+	}
+
+	if !streamRequested(req.Stream) {
+		text, done, err := collectStream(stream)
+		if err != nil {
+			c.JSON(engineErrorStatus(err), api.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, final(text, done))
+		return
+	}
+
+	startNDJSON(c)
+	last, ok := s.pipeNDJSON(c, stream, func(token string) any {
+		return api.OllamaChatResponse{
+			Model:     req.Model,
+			CreatedAt: time.Now().UTC(),
+			Message:   &api.OllamaMessage{Role: "assistant", Content: token},
+		}
+	})
+	switch {
+	case !ok:
+	case last.Type == engine.StreamError:
+		writeNDJSON(c, api.OllamaChatResponse{Model: req.Model, CreatedAt: time.Now().UTC(), Error: last.Err.Error()})
+	default:
+		writeNDJSON(c, final("", *last.Completion))
+	}
+}
+
+// pipeNDJSON forwards each token as one JSON line. It returns the done or
+// error event that ended the stream, and false if the client went away first.
+func (s *Server) pipeNDJSON(c *gin.Context, stream <-chan engine.StreamEvent, line func(string) any) (engine.StreamEvent, bool) {
+	disconnected := c.Request.Context().Done()
+	for {
+		select {
+		case <-disconnected:
+			return engine.StreamEvent{}, false
+		case ev, ok := <-stream:
+			if !ok {
+				return engine.StreamEvent{}, false
+			}
+			if ev.Type != engine.StreamToken {
+				return ev, true
+			}
+			if err := writeNDJSON(c, line(ev.Token)); err != nil {
+				return engine.StreamEvent{}, false
+			}
+		}
+	}
+}
+
+// applyOllamaOptions copies the options the client actually set
+func applyOllamaOptions(cfg *engine.InferenceConfig, opts *api.OllamaOptions) {
+	if opts == nil {
+		return
+	}
+	if opts.Temperature != nil {
+		cfg.Temperature = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		cfg.TopP = *opts.TopP
+	}
+	if opts.TopK != nil {
+		cfg.TopK = *opts.TopK
+	}
+	if opts.NumPredict != nil {
+		cfg.MaxTokens = *opts.NumPredict
+	}
+	if opts.RepeatPenalty != nil {
+		cfg.RepeatPenalty = *opts.RepeatPenalty
+	}
+	if opts.Seed != nil {
+		cfg.Seed = *opts.Seed
+	}
+	if len(opts.Stop) > 0 {
+		cfg.Stop = opts.Stop
+	}
+	if opts.MinP != nil {
+		cfg.MinP = *opts.MinP
+	}
+	if opts.TypicalP != nil {
+		cfg.TypicalP = *opts.TypicalP
+	}
+	if opts.PresencePenalty != nil {
+		cfg.PresencePenalty = *opts.PresencePenalty
+	}
+	if opts.FrequencyPenalty != nil {
+		cfg.FrequencyPenalty = *opts.FrequencyPenalty
+	}
+	if opts.Mirostat != nil {
+		cfg.Mirostat = *opts.Mirostat
+	}
+	if opts.MirostatTau != nil {
+		cfg.MirostatTau = *opts.MirostatTau
+	}
+	if opts.MirostatEta != nil {
+		cfg.MirostatEta = *opts.MirostatEta
+	}
+
+	// Load-time settings
+	if opts.NumThread != nil {
+		cfg.Threads = *opts.NumThread
+	}
+	if opts.NumCtx != nil {
+		cfg.ContextSize = *opts.NumCtx
+	}
+	if opts.NumBatch != nil {
+		cfg.BatchSize = *opts.NumBatch
+	}
+	if opts.UseMLock != nil {
+		cfg.MLock = opts.UseMLock
+	}
+	if opts.UseMMap != nil {
+		noMMap := !*opts.UseMMap
+		cfg.NoMMap = &noMMap
+	}
+}
+
+// streamRequested applies Ollama's default of streaming unless told otherwise
+func streamRequested(stream *bool) bool {
+	return stream == nil || *stream
+}
+
+// collectStream reads a whole generation. A generation that fails part way
+// returns the error and no text.
+func collectStream(stream <-chan engine.StreamEvent) (string, engine.Completion, error) {
+	var sb strings.Builder
+	var done engine.Completion
+	var err error
+	for ev := range stream {
+		switch ev.Type {
+		case engine.StreamToken:
+			sb.WriteString(ev.Token)
+		case engine.StreamError:
+			err = ev.Err
+		case engine.StreamDone:
+			done = *ev.Completion
+		}
+	}
+	if err != nil {
+		return "", done, err
+	}
+	return sb.String(), done, nil
+}
+
+// msToNanos converts engine timings to Ollama's nanosecond durations
+func msToNanos(ms float64) int64 {
+	return int64(ms * 1e6)
+}
+
+func startNDJSON(c *gin.Context) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+}
+
+func writeNDJSON(c *gin.Context, v any) error {
+	if err := json.NewEncoder(c.Writer).Encode(v); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+	"github.com/mibrahimzia/bitnet-runner/pkg/api"
+)
+
+// HandleOpenAIModels implements GET /v1/models
+func (s *Server) HandleOpenAIModels(c *gin.Context) {
+	list, err := s.modelManager.List()
+	if err != nil {
+		openAIError(c, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	data := make([]api.OpenAIModel, 0, len(list))
+	for _, m := range list {
+		data = append(data, api.OpenAIModel{
+			ID:      m.ID,
+			Object:  "model",
+			Created: m.Modified.Unix(),
+			OwnedBy: "bitnet-runner",
+		})
+	}
+	c.JSON(http.StatusOK, api.OpenAIModelList{Object: "list", Data: data})
+}
+
+// HandleOpenAIChat implements POST /v1/chat/completions
+func (s *Server) HandleOpenAIChat(c *gin.Context) {
+	var req api.OpenAIChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if len(req.Messages) == 0 {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", "messages must not be empty")
+		return
+	}
+
+	model, err := s.resolveModel(req.Model)
+	if err != nil {
+		openAIError(c, modelErrorStatus(err), "invalid_request_error", err.Error())
+		return
+	}
+
+	base, err := s.baseConfig(model, "")
+	if err != nil {
+		openAIError(c, engineErrorStatus(err), "invalid_request_error", err.Error())
+		return
+	}
+
+	id := newCompletionID("chatcmpl")
+	cfg := openAIChatConfig(base, req)
+	if err := applyOpenAIPenalties(&cfg, req.PresencePenalty, req.FrequencyPenalty, req.LogitBias); err != nil {
+		openAIError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	tagRequest(c, &cfg, id)
+
+	// The generation stops when the client disconnects
+	stream, err := s.executor.StartInference(c.Request.Context(), cfg)
+	if err != nil {
+		openAIError(c, engineErrorStatus(err), "server_error", err.Error())
+		return
+	}
+
+	created := time.Now().Unix()
+
+	if req.Stream {
+		s.streamOpenAIChat(c, stream, id, created, req.Model)
+		return
+	}
+
+	text, done, err := collectStream(stream)
+	if err != nil {
+		openAIError(c, engineErrorStatus(err), "server_error", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, api.OpenAIChatResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   req.Model,
+		Choices: []api.OpenAIChatChoice{{
+			Index:        0,
+			Message:      api.OpenAIMessage{Role: "assistant", Content: api.OpenAIContent(text)},
+			FinishReason: finishReason(done),
+		}},
+		Usage: s.openAIUsage(cfg.ModelPath, chatText(cfg), done),
+	})
+}
+
+// chatText joins the turns of a chat request, to count its prompt tokens
+// when the backend does not. The template's markers are not counted.
+func chatText(cfg engine.InferenceConfig) string {
+	var parts []string
+	for _, m := range cfg.Conversation() {
+		parts = append(parts, m.Content)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// streamOpenAIChat writes the token stream as Server-Sent Events. Returning
+// early ends the request context, which cancels the generation.
+func (s *Server) streamOpenAIChat(c *gin.Context, stream <-chan engine.StreamEvent, id string, created int64, model string) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	chunk := func(delta api.OpenAIDelta, finish *string) api.OpenAIChatChunk {
+		return api.OpenAIChatChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   model,
+			Choices: []api.OpenAIChunkChoice{{Index: 0, Delta: delta, FinishReason: finish}},
+		}
+	}
+
+	// The first chunk announces the assistant role, like the OpenAI API does
+	if err := writeSSE(c, chunk(api.OpenAIDelta{Role: "assistant"}, nil)); err != nil {
+		return
+	}
+
+	disconnected := c.Request.Context().Done()
+	for {
+		select {
+		case <-disconnected:
+			return
+		case ev, ok := <-stream:
+			if !ok {
+				return
+			}
+			switch ev.Type {
+			case engine.StreamToken:
+				if err := writeSSE(c, chunk(api.OpenAIDelta{Content: ev.Token}, nil)); err != nil {
+					return
+				}
+			case engine.StreamError:
+				writeSSEError(c, ev.Err)
+				return
+			case engine.StreamDone:
+				reason := finishReason(*ev.Completion)
+				writeSSE(c, chunk(api.OpenAIDelta{}, &reason))
+				fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+				c.Writer.Flush()
+				return
+			}
+		}
+	}
+}
+
+// openAIChatConfig maps an OpenAI chat request onto the engine configuration
+func openAIChatConfig(cfg engine.InferenceConfig, req api.OpenAIChatRequest) engine.InferenceConfig {
+	applyOpenAISampling(&cfg, req.Temperature, req.TopP, req.MaxTokens, req.Seed, req.Stop)
+
+	for _, m := range req.Messages {
+		cfg.Messages = append(cfg.Messages, engine.Message{Role: m.Role, Content: string(m.Content)})
+	}
+
+	return cfg
+}
+
+// applyOpenAISampling copies the sampling parameters the client actually set
+func applyOpenAISampling(cfg *engine.InferenceConfig, temperature, topP *float64, maxTokens, seed *int, stop []string) {
+	if len(stop) > 0 {
+		cfg.Stop = stop
+	}
+	if temperature != nil {
+		cfg.Temperature = *temperature
+	}
+	if topP != nil {
+		cfg.TopP = *topP
+	}
+	if maxTokens != nil {
+		cfg.MaxTokens = *maxTokens
+	}
+	if seed != nil {
+		cfg.Seed = *seed
+	}
+}
+
+// applyOpenAIPenalties copies the penalties and logit biases the client set.
+// OpenAI keys logit_bias by token ID as a string.
+func applyOpenAIPenalties(cfg *engine.InferenceConfig, presence, frequency *float64, logitBias map[string]float64) error {
+	if presence != nil {
+		cfg.PresencePenalty = *presence
+	}
+	if frequency != nil {
+		cfg.FrequencyPenalty = *frequency
+	}
+	for key, bias := range logitBias {
+		token, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("logit_bias keys must be token IDs, got %q", key)
+		}
+		if cfg.LogitBias == nil {
+			cfg.LogitBias = make(map[int]float64, len(logitBias))
+		}
+		cfg.LogitBias[token] = bias
+	}
+	return nil
+}
+
+// modelErrorStatus maps a model resolution error to an HTTP status
+func modelErrorStatus(err error) int {
+	if errors.Is(err, models.ErrModelNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// downloadErrorStatus maps an error resolving or starting a download to an HTTP status
+func downloadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidModelName), errors.Is(err, models.ErrInvalidReference):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrModelNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrDownloadInProgress):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// engineErrorStatus maps a model load or inference error to an HTTP status
+func engineErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, engine.ErrInvalidConfig), errors.Is(err, engine.ErrUnknownPreset):
+		return http.StatusBadRequest
+	case errors.Is(err, engine.ErrLoadTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, engine.ErrModelLoadFailed), errors.Is(err, engine.ErrEngineExited), errors.Is(err, engine.ErrStreamCut):
+		return http.StatusBadGateway
+	case errors.Is(err, engine.ErrMemoryBudget):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, engine.ErrUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, engine.ErrQueueFull):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// finishReason maps the engine's stop reason onto the "length" and "stop"
+// used by the OpenAI and Ollama APIs
+func finishReason(done engine.Completion) string {
+	if done.StopReason == engine.StopMaxTokens {
+		return "length"
+	}
+	return "stop"
+}
+
+func writeSSE(c *gin.Context, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// writeSSEError ends a stream that failed part way with an OpenAI error object,
+// as the OpenAI API does
+func writeSSEError(c *gin.Context, err error) {
+	writeSSE(c, api.OpenAIErrorResponse{Error: api.OpenAIError{Message: err.Error(), Type: "server_error"}})
+}
+
+func openAIError(c *gin.Context, status int, kind, message string) {
+	c.JSON(status, api.OpenAIErrorResponse{Error: api.OpenAIError{Message: message, Type: kind}})
+}
+
+// newCompletionID returns a random identifier such as "chatcmpl-3f2a..."
+func newCompletionID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return prefix + "-" + hex.EncodeToString(b)
+}
@@ -2,11 +2,13 @@ package server
 
 import (
 	"fmt"
+	"net/http"
 	//"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/mibrahimzia/bitnet-runner/internal/embedder"
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
 	"github.com/mibrahimzia/bitnet-runner/internal/models"
 )
 
@@ -15,43 +17,97 @@ type Server struct {
 	modelManager *models.Manager
 	port         string
 	binPath      string // Path to the extracted bitnet.exe
+	engineInfo   engine.BinaryInfo
+	executor     *engine.Executor
 }
 
-func NewServer(port string) (*Server, error) {
+// Config holds the options `bitnet serve` starts the server with
+type Config struct {
+	Port string
+	Pool   engine.PoolConfig // how many models stay loaded at once
+	Engine string            // external engine binary; empty falls back to $BITNET_ENGINE, the config file, then the embedded engine
+}
+
+func NewServer(cfg Config) (*Server, error) {
 	// 1. Initialize Model Manager
 	mm := models.NewManager()
 
-	// 2. Extract Engine Resources (ensure bitnet.exe is ready)
-	binPath, err := embedder.ExtractEngine()
+	// 2. Pick the engine binary (extracting the embedded one if needed)
+	info, err := engine.ResolveBinary(cfg.Engine)
 	if err != nil {
 		return nil, fmt.Errorf("engine init failed: %w", err)
 	}
+	binPath := info.Path
 
 	s := &Server{
 		router:       gin.Default(),
 		modelManager: mm,
-		port:         port,
+		port:         cfg.Port,
 		binPath:      binPath,
+		engineInfo:   info,
+		executor:     engine.NewPoolExecutor(binPath, cfg.Pool),
 	}
 
 	s.setupRoutes()
 	return s, nil
 }
 
+// EngineInfo reports the engine binary the server runs models with
+func (s *Server) EngineInfo() engine.BinaryInfo {
+	return s.engineInfo
+}
+
 func (s *Server) setupRoutes() {
 	// CORS configuration to allow UI to talk to localhost server
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type"}
+	config.AllowHeaders = append(config.AllowHeaders, priorityHeader)
+	config.ExposeHeaders = []string{requestIDHeader}
 	s.router.Use(cors.New(config))
 
 	api := s.router.Group("/api/v1")
 	{
 		api.GET("/models", s.HandleListModels)
+		api.GET("/models/:id", s.HandleShowModel)
 		api.POST("/models/pull", s.HandlePullModel)
+		api.GET("/presets", s.HandlePresets)
+		api.GET("/engine/status", s.HandleEngineStatus)
+		api.GET("/engine/models", s.HandleLoadedModels)
+		api.GET("/engine/events", s.HandleEngineEvents)
+		api.GET("/engine/queue", s.HandleEngineQueue)
+		api.GET("/engine/crashes", s.HandleEngineCrashes)
+		api.GET("/engine/logs", s.HandleEngineLogs)
+		api.GET("/requests", s.HandleRequests)
+		api.DELETE("/requests/:id", s.HandleCancelRequest)
+		api.POST("/tokenize", s.HandleTokenize)
+		api.POST("/embed", s.HandleEmbed)
 		// WebSocket endpoint
 		api.GET("/chat", s.HandleChatStream)
 	}
+
+	// OpenAI-compatible endpoints
+	openai := s.router.Group("/v1")
+	{
+		openai.GET("/models", s.HandleOpenAIModels)
+		openai.POST("/chat/completions", s.HandleOpenAIChat)
+		openai.POST("/completions", s.HandleOpenAICompletion)
+	}
+
+	// Ollama-compatible endpoints
+	ollama := s.router.Group("/api")
+	{
+		ollama.GET("/tags", s.HandleOllamaTags)
+		ollama.POST("/pull", s.HandleOllamaPull)
+		ollama.POST("/generate", s.HandleOllamaGenerate)
+		ollama.POST("/chat", s.HandleOllamaChat)
+	}
+}
+
+// Handler returns the HTTP handler serving all routes, for embedding the
+// server in another listener
+func (s *Server) Handler() http.Handler {
+	return s.router
 }
 
 func (s *Server) Start() error {
@@ -64,4 +120,36 @@ func (s *Server) getBinaryPath() (string, error) {
 		return "", fmt.Errorf("engine not initialized")
 	}
 	return s.binPath, nil
-}
\ No newline at end of file
+}
+
+// resolveModel finds a model by ID (or a path to a GGUF file). Models
+// configured with a remote backend resolve to their ID as the path.
+func (s *Server) resolveModel(id string) (models.ModelInfo, error) {
+	info, err := s.modelManager.Resolve(id)
+	if err != nil {
+		// Models served by a remote backend need not exist locally
+		if s.executor.IsRemote(id) {
+			return models.ModelInfo{ID: id, FilePath: id}, nil
+		}
+		return models.ModelInfo{}, err
+	}
+	return info, nil
+}
+
+// resolveModelPath maps a model ID to the file the engine loads
+func (s *Server) resolveModelPath(id string) (string, error) {
+	info, err := s.resolveModel(id)
+	return info.FilePath, err
+}
+
+// baseConfig is the configuration a generation on model starts from: the
+// preset and the model's defaults from the config file and its Modelfile,
+// which the request's own settings then override. The file is read on
+// every request so edits apply without a restart.
+func (s *Server) baseConfig(model models.ModelInfo, preset string) (engine.InferenceConfig, error) {
+	file, err := config.Load()
+	if err != nil {
+		return engine.InferenceConfig{}, err
+	}
+	return engine.ResolveConfig(file, model, preset)
+}
@@ -0,0 +1,630 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine/enginetest"
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+	"github.com/mibrahimzia/bitnet-runner/internal/models/modeltest"
+	"github.com/mibrahimzia/bitnet-runner/pkg/api"
+)
+
+const testModel = "tiny.gguf"
+
+var testTokens = []string{"Hello", ",", " world"}
+
+// newTestServer serves one installed model, tiny.gguf, from an in-process fake engine
+func newTestServer(t *testing.T, script enginetest.Script) (*Server, *enginetest.Server) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dir := modeltest.Home(t)
+	modeltest.WriteGGUF(t, filepath.Join(dir, testModel), map[string]any{
+		"general.architecture":    "llama",
+		"llama.context_length":    uint32(2048),
+		"tokenizer.chat_template": "{% for m in messages %}<|im_start|>{{ m.role }}{% endfor %}",
+	})
+
+	fake := enginetest.New(t, script)
+	executor := engine.NewPoolExecutor("", engine.PoolConfig{
+		MaxModels: 1,
+		Models:    map[string]config.ModelConfig{testModel: {URL: fake.URL}},
+	})
+	t.Cleanup(func() { executor.Shutdown() })
+
+	s := &Server{
+		router:       gin.New(),
+		modelManager: models.NewManager(),
+		executor:     executor,
+		engineInfo:   engine.BinaryInfo{Path: "fake", Source: engine.SourceFlag, Version: enginetest.Version},
+	}
+	s.setupRoutes()
+	return s, fake
+}
+
+func do(s *Server, method, path string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+// sseEvents returns the data payloads of a Server-Sent Events body
+func sseEvents(body string) []string {
+	var events []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	return events
+}
+
+func TestListModels(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{})
+
+	rec := do(s, "GET", "/api/v1/models", nil)
+	list := decode[[]models.ModelInfo](t, rec)
+	if rec.Code != http.StatusOK || len(list) != 1 || list[0].ID != testModel || list[0].Architecture != "llama" {
+		t.Errorf("GET /api/v1/models = %d %s", rec.Code, rec.Body)
+	}
+
+	rec = do(s, "GET", "/v1/models", nil)
+	openai := decode[api.OpenAIModelList](t, rec)
+	if len(openai.Data) != 1 || openai.Data[0].ID != testModel {
+		t.Errorf("GET /v1/models = %s", rec.Body)
+	}
+
+	rec = do(s, "GET", "/api/tags", nil)
+	tags := decode[api.OllamaTagsResponse](t, rec)
+	if len(tags.Models) != 1 || tags.Models[0].Details.Family != "llama" {
+		t.Errorf("GET /api/tags = %s", rec.Body)
+	}
+}
+
+func TestOpenAIChat(t *testing.T) {
+	s, fake := newTestServer(t, enginetest.Script{Tokens: testTokens})
+
+	rec := do(s, "POST", "/v1/chat/completions", api.OpenAIChatRequest{
+		Model:    testModel,
+		Messages: []api.OpenAIMessage{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hi"}},
+		Stop:     api.StopSequences{"STOP"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	resp := decode[api.OpenAIChatResponse](t, rec)
+	if rec.Header().Get(requestIDHeader) != resp.ID {
+		t.Errorf("%s = %q, want the completion ID %q", requestIDHeader, rec.Header().Get(requestIDHeader), resp.ID)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "Hello, world" || resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	// The model's own chat template (detected as chatml) renders the turns
+	req := fake.Requests()[0]
+	if !strings.Contains(req.Prompt, "<|im_start|>system\nbe brief") || req.Stop[0] != "STOP" {
+		t.Errorf("engine request = %+v", req)
+	}
+	prompt := len(strings.Fields(req.Prompt))
+	want := api.OpenAIUsage{PromptTokens: prompt, CompletionTokens: 3, TotalTokens: prompt + 3}
+	if resp.Usage == nil || *resp.Usage != want {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+}
+
+func TestOpenAIChatContentParts(t *testing.T) {
+	s, fake := newTestServer(t, enginetest.Script{Tokens: testTokens})
+
+	parts := []map[string]any{{"type": "text", "text": "first part"}, {"type": "text", "text": "second part"}}
+	rec := do(s, "POST", "/v1/chat/completions", map[string]any{
+		"model":    testModel,
+		"messages": []map[string]any{{"role": "system", "content": "be brief"}, {"role": "user", "content": parts}},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if prompt := fake.Requests()[0].Prompt; !strings.Contains(prompt, "<|im_start|>user\nfirst part\nsecond part<|im_end|>") {
+		t.Errorf("prompt = %q, want the text parts joined", prompt)
+	}
+
+	image := []map[string]any{{"type": "image_url", "image_url": map[string]any{"url": "https://example.com/cat.png"}}}
+	rec = do(s, "POST", "/v1/chat/completions", map[string]any{
+		"model":    testModel,
+		"messages": []map[string]any{{"role": "user", "content": image}},
+	})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "image_url") {
+		t.Errorf("image content: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOpenAIChatStream(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{Tokens: testTokens})
+	maxTokens := 3
+
+	rec := do(s, "POST", "/v1/chat/completions", api.OpenAIChatRequest{
+		Model:     testModel,
+		Messages:  []api.OpenAIMessage{{Role: "user", Content: "hi"}},
+		MaxTokens: &maxTokens,
+		Stream:    true,
+	})
+	events := sseEvents(rec.Body.String())
+	if len(events) != len(testTokens)+3 || events[len(events)-1] != "[DONE]" {
+		t.Fatalf("events = %q", events)
+	}
+
+	var content strings.Builder
+	for i, data := range events[:len(events)-1] {
+		var chunk api.OpenAIChatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		delta := chunk.Choices[0].Delta
+		switch i {
+		case 0:
+			if delta.Role != "assistant" {
+				t.Errorf("first chunk = %s, want the assistant role", data)
+			}
+		case len(events) - 2:
+			if reason := chunk.Choices[0].FinishReason; reason == nil || *reason != "length" {
+				t.Errorf("last chunk = %s, want finish_reason length", data)
+			}
+		}
+		content.WriteString(delta.Content)
+	}
+	if content.String() != "Hello, world" {
+		t.Errorf("streamed content = %q", content.String())
+	}
+}
+
+func TestOpenAICompletionUsage(t *testing.T) {
+	s, fake := newTestServer(t, enginetest.Script{Tokens: testTokens})
+
+	rec := do(s, "POST", "/v1/completions", map[string]any{"model": testModel, "prompt": "one two three"})
+	resp := decode[api.OpenAICompletionResponse](t, rec)
+	if resp.Choices[0].Text != "Hello, world" {
+		t.Errorf("text = %q", resp.Choices[0].Text)
+	}
+	want := api.OpenAIUsage{PromptTokens: 3, CompletionTokens: 3, TotalTokens: 6}
+	if resp.Usage == nil || *resp.Usage != want {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+	if prompt := fake.Requests()[0].Prompt; prompt != "one two three" {
+		t.Errorf("prompt = %q, want it sent verbatim", prompt)
+	}
+}
+
+func TestOllamaGenerate(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{Tokens: testTokens})
+	stream := false
+
+	rec := do(s, "POST", "/api/generate", api.OllamaGenerateRequest{Model: testModel, Prompt: "hi", Stream: &stream})
+	resp := decode[api.OllamaGenerateResponse](t, rec)
+	if resp.Response != "Hello, world" || !resp.Done || resp.EvalCount != 3 {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.PromptEvalCount == 0 || resp.EvalDuration == 0 {
+		t.Errorf("engine counts and timings missing: %+v", resp)
+	}
+
+	// Streaming is the default: one JSON object per line, then a final one
+	rec = do(s, "POST", "/api/generate", api.OllamaGenerateRequest{Model: testModel, Prompt: "hi"})
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != len(testTokens)+1 {
+		t.Fatalf("lines = %q", lines)
+	}
+	var last api.OllamaGenerateResponse
+	json.Unmarshal([]byte(lines[len(lines)-1]), &last)
+	if !last.Done || last.DoneReason != "stop" {
+		t.Errorf("final line = %s", lines[len(lines)-1])
+	}
+}
+
+func TestOllamaChat(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{Tokens: testTokens})
+	stream := false
+
+	rec := do(s, "POST", "/api/chat", api.OllamaChatRequest{
+		Model:    testModel,
+		Messages: []api.OllamaMessage{{Role: "user", Content: "hi"}},
+		Stream:   &stream,
+	})
+	resp := decode[api.OllamaChatResponse](t, rec)
+	if resp.Message == nil || resp.Message.Content != "Hello, world" || !resp.Done {
+		t.Errorf("response = %s", rec.Body)
+	}
+
+	// No messages only loads the model
+	rec = do(s, "POST", "/api/chat", api.OllamaChatRequest{Model: testModel})
+	if resp := decode[api.OllamaChatResponse](t, rec); resp.DoneReason != "load" {
+		t.Errorf("load response = %s", rec.Body)
+	}
+}
+
+func TestStreamCut(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{Tokens: testTokens, CutAfter: 1})
+
+	rec := do(s, "POST", "/v1/chat/completions", api.OpenAIChatRequest{
+		Model:    testModel,
+		Messages: []api.OpenAIMessage{{Role: "user", Content: "hi"}},
+	})
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "before the generation finished") {
+		t.Errorf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	// A stream that has started ends with an error line instead of done
+	rec = do(s, "POST", "/api/generate", api.OllamaGenerateRequest{Model: testModel, Prompt: "hi"})
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	var last api.OllamaGenerateResponse
+	json.Unmarshal([]byte(lines[len(lines)-1]), &last)
+	if len(lines) != 2 || last.Done || last.Error == "" {
+		t.Errorf("lines = %q", lines)
+	}
+}
+
+func TestSamplingParameters(t *testing.T) {
+	s, fake := newTestServer(t, enginetest.Script{Tokens: testTokens})
+	stream := false
+	minP, presence, mirostat := 0.1, 0.5, 1
+
+	rec := do(s, "POST", "/api/generate", api.OllamaGenerateRequest{
+		Model:   testModel,
+		Prompt:  "hi",
+		Stream:  &stream,
+		Options: &api.OllamaOptions{MinP: &minP, PresencePenalty: &presence, Mirostat: &mirostat},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if req := fake.Requests()[0]; req.MinP != 0.1 || req.PresencePenalty != 0.5 || req.Mirostat != 1 {
+		t.Errorf("engine request = %+v", req)
+	}
+
+	rec = do(s, "POST", "/v1/chat/completions", api.OpenAIChatRequest{
+		Model:     testModel,
+		Messages:  []api.OpenAIMessage{{Role: "user", Content: "hi"}},
+		LogitBias: map[string]float64{"15043": -100},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if req := fake.Requests()[1]; len(req.LogitBias) != 1 || req.LogitBias[0] != [2]float64{15043, -100} {
+		t.Errorf("logit_bias = %v", req.LogitBias)
+	}
+}
+
+func TestInvalidSampling(t *testing.T) {
+	s, fake := newTestServer(t, enginetest.Script{Tokens: testTokens})
+	temperature := 5.0
+
+	for name, req := range map[string]api.OpenAIChatRequest{
+		"temperature":    {Temperature: &temperature},
+		"logit_bias key": {LogitBias: map[string]float64{"hello": 1}},
+	} {
+		req.Model = testModel
+		req.Messages = []api.OpenAIMessage{{Role: "user", Content: "hi"}}
+		if rec := do(s, "POST", "/v1/chat/completions", req); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", name, rec.Code, rec.Body)
+		}
+	}
+	if len(fake.Requests()) != 0 {
+		t.Error("invalid requests reached the engine")
+	}
+}
+
+// writeConfig saves the config file of the test's home directory
+func writeConfig(t *testing.T, cfg config.Config) {
+	t.Helper()
+	path, err := config.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(cfg)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPresetsAndModelDefaults(t *testing.T) {
+	s, fake := newTestServer(t, enginetest.Script{Tokens: testTokens})
+	topK := 5
+	writeConfig(t, config.Config{
+		Presets: map[string]config.Params{"terse": {TopK: &topK}},
+		Models:  map[string]config.ModelConfig{testModel: {Preset: "code", Defaults: config.Params{SystemPrompt: "be brief"}}},
+	})
+
+	// The model's preset and defaults fill in what the request leaves out
+	temperature := 0.7
+	rec := do(s, "POST", "/v1/chat/completions", api.OpenAIChatRequest{
+		Model:       testModel,
+		Messages:    []api.OpenAIMessage{{Role: "user", Content: "hi"}},
+		Temperature: &temperature,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	req := fake.Requests()[0]
+	if req.Temperature != 0.7 || req.RepeatPenalty != 1.0 || req.NPredict != 1024 {
+		t.Errorf("temperature %g, repeat_penalty %g, n_predict %d", req.Temperature, req.RepeatPenalty, req.NPredict)
+	}
+	if !strings.Contains(req.Prompt, "be brief") {
+		t.Errorf("prompt %q lacks the model's system prompt", req.Prompt)
+	}
+
+	rec = do(s, "GET", "/api/v1/presets", nil)
+	presets := decode[map[string]config.Params](t, rec)
+	if p, ok := presets["terse"]; !ok || *p.TopK != 5 || presets["creative"].Temperature == nil {
+		t.Errorf("presets = %s", rec.Body)
+	}
+
+	// A model naming a preset that does not exist is a bad request
+	writeConfig(t, config.Config{Models: map[string]config.ModelConfig{testModel: {Preset: "missing"}}})
+	stream := false
+	rec = do(s, "POST", "/api/generate", api.OllamaGenerateRequest{Model: testModel, Prompt: "hi", Stream: &stream})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unknown preset") {
+		t.Errorf("status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestDerivedModel(t *testing.T) {
+	s, fake := newTestServer(t, enginetest.Script{Tokens: testTokens})
+	mf, err := models.ParseModelfile(strings.NewReader("FROM tiny.gguf\nSYSTEM be a pirate\nPARAMETER top_k 7\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.modelManager.Create("pirate", mf); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := do(s, "GET", "/v1/models", nil)
+	if list := decode[api.OpenAIModelList](t, rec); len(list.Data) != 2 || list.Data[1].ID != "pirate" {
+		t.Errorf("models = %s", rec.Body)
+	}
+
+	// The derived model runs on its base with the Modelfile's settings
+	rec = do(s, "POST", "/v1/chat/completions", api.OpenAIChatRequest{
+		Model:    "pirate",
+		Messages: []api.OpenAIMessage{{Role: "user", Content: "hi"}},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if req := fake.Requests()[0]; req.TopK != 7 || !strings.Contains(req.Prompt, "be a pirate") {
+		t.Errorf("engine request = %+v", req)
+	}
+	if loaded := s.executor.Loaded(); len(loaded) != 1 || filepath.Base(loaded[0].ModelPath) != testModel {
+		t.Errorf("loaded = %+v", loaded)
+	}
+}
+
+func TestPull(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{})
+	data, err := os.ReadFile(modeltest.WriteGGUF(t, filepath.Join(t.TempDir(), "remote.gguf"), map[string]any{"general.architecture": "llama"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer remote.Close()
+
+	rec := do(s, "POST", "/api/pull", api.OllamaPullRequest{Model: remote.URL + "/pulled.gguf", Stream: new(bool), SHA256: hex.EncodeToString(sum[:])})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := s.modelManager.Find("pulled.gguf"); err != nil {
+		t.Errorf("pulled model not installed: %v", err)
+	}
+
+	rec = do(s, "POST", "/api/pull", api.OllamaPullRequest{Model: remote.URL + "/other.gguf", Stream: new(bool), SHA256: strings.Repeat("0", 64)})
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "checksum mismatch") {
+		t.Errorf("bad checksum: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = do(s, "POST", "/api/pull", api.OllamaPullRequest{Model: "not a model"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid reference: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = do(s, "POST", "/api/v1/models/pull", api.ModelDownloadRequest{Url: remote.URL + "/x.gguf", Name: "../escape.gguf"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("name outside the models directory: status %d: %s", rec.Code, rec.Body)
+	}
+
+	// A direct URL with a name is downloaded as it is, whatever its path
+	rec = do(s, "POST", "/api/v1/models/pull", api.ModelDownloadRequest{Url: remote.URL + "/download?id=7"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("URL without a name or .gguf file: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = do(s, "POST", "/api/v1/models/pull", api.ModelDownloadRequest{Url: remote.URL + "/download?id=7", Name: "named.gguf"})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("URL with a name: status %d: %s", rec.Code, rec.Body)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := s.modelManager.Find("named.gguf"); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("named download not installed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUnknownModel(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{})
+
+	for _, path := range []string{"/v1/chat/completions", "/api/chat", "/api/v1/tokenize"} {
+		rec := do(s, "POST", path, map[string]any{
+			"model":    "missing.gguf",
+			"messages": []api.OpenAIMessage{{Role: "user", Content: "hi"}},
+		})
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+func TestEngineErrors(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{LoadError: "failed to load model"})
+
+	rec := do(s, "POST", "/v1/chat/completions", api.OpenAIChatRequest{
+		Model:    testModel,
+		Messages: []api.OpenAIMessage{{Role: "user", Content: "hi"}},
+	})
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "failed to load model") {
+		t.Errorf("status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestEngineErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: temperature must be between 0 and 2, got 5", engine.ErrInvalidConfig), http.StatusBadRequest},
+		{fmt.Errorf(`%w: "fast"`, engine.ErrUnknownPreset), http.StatusBadRequest},
+		{&engine.LoadError{Kind: engine.ErrLoadTimeout}, http.StatusGatewayTimeout},
+		{&engine.LoadError{Kind: engine.ErrModelLoadFailed}, http.StatusBadGateway},
+		{&engine.LoadError{Kind: engine.ErrEngineExited}, http.StatusBadGateway},
+		{fmt.Errorf("load: %w", engine.ErrMemoryBudget), http.StatusInsufficientStorage},
+		{fmt.Errorf("tokenize: %w", engine.ErrUnsupported), http.StatusNotImplemented},
+		{fmt.Errorf("%w: tiny.gguf has 32 queued", engine.ErrQueueFull), http.StatusTooManyRequests},
+		{errors.New("connection failed"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := engineErrorStatus(tt.err); got != tt.want {
+			t.Errorf("engineErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{})
+
+	if rec := do(s, "DELETE", "/api/v1/requests/req-unknown", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("cancel unknown request: status %d", rec.Code)
+	}
+	rec := do(s, "GET", "/api/v1/requests", nil)
+	if list := decode[[]engine.ActiveRequest](t, rec); len(list) != 0 {
+		t.Errorf("requests = %s", rec.Body)
+	}
+}
+
+func TestChatStreamRequestID(t *testing.T) {
+	tokens := strings.Split(strings.Repeat("x", 100), "")
+	s, _ := newTestServer(t, enginetest.Script{Tokens: tokens, TokenDelay: 10 * time.Millisecond})
+	srv := httptest.NewServer(s.router)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(api.ChatRequest{Model: testModel, Prompt: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first frame names the request, which can then be cancelled over HTTP
+	var first api.ChatResponse
+	if err := conn.ReadJSON(&first); err != nil || first.RequestID == "" || first.Content != "" {
+		t.Fatalf("first frame = %+v, %v", first, err)
+	}
+	var resp api.ChatResponse
+	if err := conn.ReadJSON(&resp); err != nil || resp.Content != "x" {
+		t.Fatalf("second frame = %+v, %v", resp, err)
+	}
+	if rec := do(s, "DELETE", "/api/v1/requests/"+first.RequestID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("cancel: status %d: %s", rec.Code, rec.Body)
+	}
+	for !resp.Done {
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resp.StopReason != engine.StopCancelled {
+		t.Errorf("last frame = %+v, want cancelled", resp)
+	}
+}
+
+func TestTokenizeAndEmbed(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{})
+
+	rec := do(s, "POST", "/api/v1/tokenize", api.TokenizeRequest{Model: testModel, Content: "a b"})
+	if resp := decode[api.TokenizeResponse](t, rec); len(resp.Tokens) != 2 {
+		t.Errorf("tokenize = %s", rec.Body)
+	}
+
+	rec = do(s, "POST", "/api/v1/embed", api.EmbedRequest{Model: testModel, Content: "abcd"})
+	if resp := decode[api.EmbedResponse](t, rec); len(resp.Embedding) != 3 || resp.Embedding[0] != 4 {
+		t.Errorf("embed = %s", rec.Body)
+	}
+}
+
+func TestEngineStatus(t *testing.T) {
+	s, fake := newTestServer(t, enginetest.Script{})
+
+	info, err := s.modelManager.Find(testModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.executor.LoadModel(info.FilePath); err != nil {
+		t.Fatal(err)
+	}
+	rec := do(s, "GET", "/api/v1/engine/status", nil)
+	status := decode[EngineStatus](t, rec)
+	if status.Engine.Version != enginetest.Version || len(status.Models) != 1 || status.Crashes != 0 {
+		t.Errorf("status = %s", rec.Body)
+	}
+	if m := status.Models[0]; m.State != "ready" || m.Backend != engine.BackendLlamaServer {
+		t.Errorf("model = %+v (engine at %s)", m, fake.URL)
+	}
+}
+
+func TestEngineLogsLines(t *testing.T) {
+	s, _ := newTestServer(t, enginetest.Script{})
+
+	for _, lines := range []string{"-1", "x"} {
+		if rec := do(s, "GET", "/api/v1/engine/logs?lines="+lines, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("lines=%s: status %d, want 400", lines, rec.Code)
+		}
+	}
+	// Far more than the store keeps is the same as all of them
+	if rec := do(s, "GET", "/api/v1/engine/logs?lines=2000000000", nil); rec.Code != http.StatusOK {
+		t.Errorf("lines=2000000000: status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,169 @@
+package templates
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/models"
+)
+
+// DefaultName is used when a model carries no recognizable chat template
+const DefaultName = "bitnet"
+
+// Message is a single turn of a conversation
+type Message struct {
+	Role    string `json:"role"` // "system", "user" or "assistant"
+	Content string `json:"content"`
+}
+
+// Template turns a list of messages into the prompt format a model was trained on
+type Template struct {
+	Name string
+	Stop []string // end-of-turn markers that should stop generation
+
+	render func(msgs []Message) string
+}
+
+// Render formats the conversation and opens the assistant's reply.
+// The BOS token is not included because the engine adds it itself.
+func (t *Template) Render(msgs []Message) string {
+	return t.render(msgs)
+}
+
+var builtins = map[string]*Template{
+	"llama3": {
+		Name: "llama3",
+		Stop: []string{"<|eot_id|>"},
+		render: func(msgs []Message) string {
+			var sb strings.Builder
+			for _, m := range msgs {
+				sb.WriteString("<|start_header_id|>" + m.Role + "<|end_header_id|>\n\n" + m.Content + "<|eot_id|>")
+			}
+			sb.WriteString("<|start_header_id|>assistant<|end_header_id|>\n\n")
+			return sb.String()
+		},
+	},
+	"chatml": {
+		Name: "chatml",
+		Stop: []string{"<|im_end|>"},
+		render: func(msgs []Message) string {
+			var sb strings.Builder
+			for _, m := range msgs {
+				sb.WriteString("<|im_start|>" + m.Role + "\n" + m.Content + "<|im_end|>\n")
+			}
+			sb.WriteString("<|im_start|>assistant\n")
+			return sb.String()
+		},
+	},
+	// The format BitNet b1.58 models were tuned on
+	"bitnet": {
+		Name: "bitnet",
+		Stop: []string{"<|eot_id|>"},
+		render: func(msgs []Message) string {
+			var sb strings.Builder
+			for _, m := range msgs {
+				switch m.Role {
+				case "system":
+					sb.WriteString("System: " + m.Content)
+				case "user":
+					sb.WriteString("User: " + m.Content + "<|eot_id|>")
+				case "assistant":
+					sb.WriteString("Assistant: " + m.Content + "<|eot_id|>")
+				}
+			}
+			sb.WriteString("Assistant:")
+			return sb.String()
+		},
+	},
+	// Mistral has no system role: the system prompt is folded into the first user turn
+	"mistral": {
+		Name: "mistral",
+		Stop: []string{"</s>", "[INST]"},
+		render: func(msgs []Message) string {
+			var sb strings.Builder
+			system := ""
+			for _, m := range msgs {
+				switch m.Role {
+				case "system":
+					system = m.Content
+				case "user":
+					content := m.Content
+					if system != "" {
+						content = system + "\n\n" + content
+						system = ""
+					}
+					sb.WriteString("[INST] " + content + " [/INST]")
+				case "assistant":
+					sb.WriteString(" " + m.Content + "</s>")
+				}
+			}
+			return sb.String()
+		},
+	},
+	"phi": {
+		Name: "phi",
+		Stop: []string{"<|end|>"},
+		render: func(msgs []Message) string {
+			var sb strings.Builder
+			for _, m := range msgs {
+				sb.WriteString("<|" + m.Role + "|>\n" + m.Content + "<|end|>\n")
+			}
+			sb.WriteString("<|assistant|>\n")
+			return sb.String()
+		},
+	},
+}
+
+// Get returns a built-in template by name
+func Get(name string) (*Template, error) {
+	t, ok := builtins[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown chat template %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return t, nil
+}
+
+// Names lists the built-in templates
+func Names() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Detect maps a Jinja chat template, as stored under tokenizer.chat_template,
+// to the built-in template that produces the same format
+func Detect(source string) (*Template, bool) {
+	var name string
+	switch {
+	case source == "":
+		return nil, false
+	case strings.Contains(source, "<|start_header_id|>"):
+		name = "llama3"
+	case strings.Contains(source, "<|im_start|>"):
+		name = "chatml"
+	case strings.Contains(source, "[INST]"):
+		name = "mistral"
+	case strings.Contains(source, "<|assistant|>") && strings.Contains(source, "<|end|>"):
+		name = "phi"
+	case strings.Contains(source, "User: ") && strings.Contains(source, "<|eot_id|>"):
+		name = "bitnet"
+	default:
+		return nil, false
+	}
+	return builtins[name], true
+}
+
+// ForModel picks the template for a GGUF file from its embedded
+// tokenizer.chat_template, falling back to DefaultName
+func ForModel(path string) *Template {
+	if file, err := models.ParseGGUF(path); err == nil {
+		if t, ok := Detect(file.String("tokenizer.chat_template")); ok {
+			return t
+		}
+	}
+	return builtins[DefaultName]
+}
@@ -0,0 +1,128 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mibrahimzia/bitnet-runner/internal/models/modeltest"
+)
+
+// Jinja chat templates as found under tokenizer.chat_template, shortened
+const (
+	llama3Source  = "{% for message in messages %}{{ '<|start_header_id|>' + message['role'] + '<|end_header_id|>\n\n' + message['content'] + '<|eot_id|>' }}{% endfor %}"
+	chatmlSource  = "{% for message in messages %}{{'<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>' + '\n'}}{% endfor %}"
+	mistralSource = "{{ bos_token }}{% for message in messages %}{% if message['role'] == 'user' %}{{ '[INST] ' + message['content'] + ' [/INST]' }}{% endif %}{% endfor %}"
+	phiSource     = "{% for message in messages %}{{'<|' + message['role'] + '|>' + '\n' + message['content'] + '<|end|>\n'}}{% endfor %}{{ '<|assistant|>\n' }}"
+	bitnetSource  = "{% for message in messages %}{% if message['role'] == 'user' %}{{ 'User: ' + message['content'] + '<|eot_id|>' }}{% endif %}{% endfor %}"
+	falconSource  = "{% for message in messages %}{% if message['role'] == 'user' %}{{ 'User: ' + message['content'] + '\n' }}{% endif %}{% endfor %}Falcon:"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string // empty when nothing matches
+	}{
+		{llama3Source, "llama3"},
+		{chatmlSource, "chatml"},
+		{mistralSource, "mistral"},
+		{phiSource, "phi"},
+		{bitnetSource, "bitnet"},
+		{falconSource, ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := Detect(tt.source)
+		switch {
+		case tt.want == "" && ok:
+			t.Errorf("Detect(%.40q) = %s, want no match", tt.source, got.Name)
+		case tt.want != "" && (!ok || got.Name != tt.want):
+			t.Errorf("Detect(%.40q) = %v, %v; want %s", tt.source, got, ok, tt.want)
+		}
+	}
+}
+
+func TestForModel(t *testing.T) {
+	dir := t.TempDir()
+	model := func(name string, metadata map[string]any) string {
+		return modeltest.WriteGGUF(t, filepath.Join(dir, name), metadata)
+	}
+	notGGUF := filepath.Join(dir, "notes.gguf")
+	if err := os.WriteFile(notGGUF, []byte("not a model"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{model("chatml.gguf", map[string]any{"tokenizer.chat_template": chatmlSource}), "chatml"},
+		{model("llama3.gguf", map[string]any{"tokenizer.chat_template": llama3Source}), "llama3"},
+		{model("mistral.gguf", map[string]any{"tokenizer.chat_template": mistralSource}), "mistral"},
+		// Unknown formats and models without a template use the default
+		{model("falcon.gguf", map[string]any{"tokenizer.chat_template": falconSource}), DefaultName},
+		{model("plain.gguf", map[string]any{"general.architecture": "bitnet"}), DefaultName},
+		{notGGUF, DefaultName},
+		{filepath.Join(dir, "missing.gguf"), DefaultName},
+	}
+	for _, tt := range tests {
+		if got := ForModel(tt.path); got.Name != tt.want {
+			t.Errorf("ForModel(%s) = %s, want %s", filepath.Base(tt.path), got.Name, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	conversation := []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hi"},
+		{Role: "assistant", Content: "Hello"},
+		{Role: "user", Content: "Bye"},
+	}
+	tests := map[string]string{
+		"llama3": "<|start_header_id|>system<|end_header_id|>\n\nBe brief.<|eot_id|>" +
+			"<|start_header_id|>user<|end_header_id|>\n\nHi<|eot_id|>" +
+			"<|start_header_id|>assistant<|end_header_id|>\n\nHello<|eot_id|>" +
+			"<|start_header_id|>user<|end_header_id|>\n\nBye<|eot_id|>" +
+			"<|start_header_id|>assistant<|end_header_id|>\n\n",
+		"chatml": "<|im_start|>system\nBe brief.<|im_end|>\n" +
+			"<|im_start|>user\nHi<|im_end|>\n" +
+			"<|im_start|>assistant\nHello<|im_end|>\n" +
+			"<|im_start|>user\nBye<|im_end|>\n" +
+			"<|im_start|>assistant\n",
+		"bitnet": "System: Be brief.User: Hi<|eot_id|>Assistant: Hello<|eot_id|>User: Bye<|eot_id|>Assistant:",
+		// The system prompt is folded into the first user turn
+		"mistral": "[INST] Be brief.\n\nHi [/INST] Hello</s>[INST] Bye [/INST]",
+		"phi": "<|system|>\nBe brief.<|end|>\n" +
+			"<|user|>\nHi<|end|>\n" +
+			"<|assistant|>\nHello<|end|>\n" +
+			"<|user|>\nBye<|end|>\n" +
+			"<|assistant|>\n",
+	}
+	for name, want := range tests {
+		tmpl, err := Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tmpl.Render(conversation); got != want {
+			t.Errorf("%s rendered\n%q\nwant\n%q", name, got, want)
+		}
+		if len(tmpl.Stop) == 0 {
+			t.Errorf("%s has no stop strings", name)
+		}
+	}
+	if len(tests) != len(Names()) {
+		t.Errorf("rendering tested for %d of %d templates", len(tests), len(Names()))
+	}
+}
+
+func TestGet(t *testing.T) {
+	if tmpl, err := Get("ChatML"); err != nil || tmpl.Name != "chatml" {
+		t.Errorf("Get(ChatML) = %v, %v", tmpl, err)
+	}
+	_, err := Get("falcon")
+	if err == nil || !strings.Contains(err.Error(), strings.Join(Names(), ", ")) {
+		t.Errorf("Get(falcon) err = %v, want the available names", err)
+	}
+}
@@ -35,6 +35,15 @@ func GetRuntimeDir() (string, error) {
 	return filepath.Join(appDir, "bin", runtime.GOOS), nil
 }
 
+// GetLogsDir returns the directory where engine logs are written
+func GetLogsDir() (string, error) {
+	appDir, err := GetAppDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, "logs"), nil
+}
+
 // EnsureDir creates a directory if it doesn't exist
 func EnsureDir(path string) error {
 	return os.MkdirAll(path, 0755)
@@ -0,0 +1,18 @@
+//go:build darwin
+
+package darwin
+
+import (
+	"embed"
+)
+
+// bitnet-server is the BitNet build of llama-server, linked statically so
+// no shared libraries need to ship next to it
+//
+//go:embed bitnet-server
+var BitNetFS embed.FS
+
+// GetFS returns the embedded filesystem containing the macOS binaries
+func GetFS() embed.FS {
+	return BitNetFS
+}
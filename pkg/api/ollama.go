@@ -0,0 +1,123 @@
+package api
+
+import "time"
+
+// OllamaOptions holds the subset of Ollama's "options" object the engine understands.
+// Fields are pointers so unset values fall back to engine defaults.
+type OllamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	TopK             *int     `json:"top_k,omitempty"`
+	MinP             *float64 `json:"min_p,omitempty"`
+	TypicalP         *float64 `json:"typical_p,omitempty"`
+	NumPredict       *int     `json:"num_predict,omitempty"`
+	RepeatPenalty    *float64 `json:"repeat_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	Mirostat         *int     `json:"mirostat,omitempty"`
+	MirostatTau      *float64 `json:"mirostat_tau,omitempty"`
+	MirostatEta      *float64 `json:"mirostat_eta,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+
+	// Applied when the request loads the model
+	NumThread *int  `json:"num_thread,omitempty"`
+	NumCtx    *int  `json:"num_ctx,omitempty"`
+	NumBatch  *int  `json:"num_batch,omitempty"`
+	UseMLock  *bool `json:"use_mlock,omitempty"`
+	UseMMap   *bool `json:"use_mmap,omitempty"`
+}
+
+// OllamaMessage is a single chat turn in the Ollama protocol
+type OllamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OllamaGenerateRequest is the body of POST /api/generate
+type OllamaGenerateRequest struct {
+	Model   string         `json:"model"`
+	Prompt  string         `json:"prompt"`
+	System  string         `json:"system,omitempty"`
+	Raw     bool           `json:"raw,omitempty"`
+	Stream  *bool          `json:"stream,omitempty"` // defaults to true
+	Options *OllamaOptions `json:"options,omitempty"`
+}
+
+// OllamaChatRequest is the body of POST /api/chat
+type OllamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	Stream   *bool           `json:"stream,omitempty"` // defaults to true
+	Options  *OllamaOptions  `json:"options,omitempty"`
+}
+
+// OllamaGenerateResponse is one NDJSON line of /api/generate
+type OllamaGenerateResponse struct {
+	Model              string    `json:"model"`
+	CreatedAt          time.Time `json:"created_at"`
+	Response           string    `json:"response"`
+	Done               bool      `json:"done"`
+	DoneReason         string    `json:"done_reason,omitempty"`
+	TotalDuration      int64     `json:"total_duration,omitempty"` // nanoseconds
+	PromptEvalCount    int       `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64     `json:"prompt_eval_duration,omitempty"` // nanoseconds
+	EvalCount          int       `json:"eval_count,omitempty"`
+	EvalDuration       int64     `json:"eval_duration,omitempty"` // nanoseconds
+	Error              string    `json:"error,omitempty"`         // generation failed part way; ends the stream
+}
+
+// OllamaChatResponse is one NDJSON line of /api/chat
+type OllamaChatResponse struct {
+	Model              string         `json:"model"`
+	CreatedAt          time.Time      `json:"created_at"`
+	Message            *OllamaMessage `json:"message,omitempty"`
+	Done               bool           `json:"done"`
+	DoneReason         string         `json:"done_reason,omitempty"`
+	TotalDuration      int64          `json:"total_duration,omitempty"` // nanoseconds
+	PromptEvalCount    int            `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64          `json:"prompt_eval_duration,omitempty"` // nanoseconds
+	EvalCount          int            `json:"eval_count,omitempty"`
+	EvalDuration       int64          `json:"eval_duration,omitempty"` // nanoseconds
+	Error              string         `json:"error,omitempty"`         // generation failed part way; ends the stream
+}
+
+// OllamaModelDetails describes the format of a listed model
+type OllamaModelDetails struct {
+	Format            string `json:"format"`
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+// OllamaModel is one entry of GET /api/tags
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Model      string             `json:"model"`
+	ModifiedAt time.Time          `json:"modified_at"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+// OllamaTagsResponse is the body of GET /api/tags
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+// OllamaPullRequest is the body of POST /api/pull. Model is a direct URL to
+// a .gguf file or a Hugging Face reference such as hf.co/org/repo:quant.
+type OllamaPullRequest struct {
+	Model  string `json:"model"`
+	Name   string `json:"name,omitempty"` // deprecated alias of Model
+	Stream *bool  `json:"stream,omitempty"`
+	SHA256 string `json:"sha256,omitempty"` // expected digest of the file; not part of Ollama's API
+}
+
+// OllamaProgressResponse is one NDJSON progress line of /api/pull
+type OllamaProgressResponse struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
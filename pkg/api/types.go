@@ -1,30 +1,105 @@
 package api
 
-// ChatRequest is the payload sent by the UI to start generation
+// Message is a single turn of a conversation
+type Message struct {
+	Role    string `json:"role"` // "system", "user" or "assistant"
+	Content string `json:"content"`
+}
+
+// ChatRequest is the payload sent by the UI to start generation. Zero
+// values of the optional settings leave them to the engine.
 type ChatRequest struct {
-	Model       string  `json:"model"`
-	Prompt      string  `json:"prompt"`
-	System      string  `json:"system_prompt"`
-	Temperature float64 `json:"temperature"`
-	TopP        float64 `json:"top_p"`
-	TopK        int     `json:"top_k"`
-	MaxTokens   int     `json:"max_tokens"`
-	Stream      bool    `json:"stream"` // If true, use WebSocket
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"` // prior turns; Prompt is appended as the newest user turn
+	Prompt   string    `json:"prompt"`
+	System   string    `json:"system_prompt"`
+	Stream   bool      `json:"stream"`           // If true, use WebSocket
+	Template string    `json:"template"`         // Optional built-in chat template name
+	Priority int       `json:"priority"`         // Higher runs first when the server orders its queue by priority
+	Preset   string    `json:"preset,omitempty"` // Named generation preset; empty uses the model's
+
+	// Optional sampling settings; unset ones come from the model's defaults,
+	// the preset, then the server defaults
+	Temperature      *float64        `json:"temperature,omitempty"`
+	TopP             *float64        `json:"top_p,omitempty"`
+	TopK             *int            `json:"top_k,omitempty"`
+	MaxTokens        *int            `json:"max_tokens,omitempty"`
+	RepeatPenalty    *float64        `json:"repeat_penalty,omitempty"`
+	Seed             *int            `json:"seed,omitempty"` // -1 for a random seed
+	Stop             []string        `json:"stop,omitempty"`
+	MinP             *float64        `json:"min_p,omitempty"`
+	TypicalP         *float64        `json:"typical_p,omitempty"`
+	PresencePenalty  *float64        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64        `json:"frequency_penalty,omitempty"`
+	Mirostat         *int            `json:"mirostat,omitempty"` // 1 or 2 selects Mirostat v1 or v2
+	MirostatTau      *float64        `json:"mirostat_tau,omitempty"`
+	MirostatEta      *float64        `json:"mirostat_eta,omitempty"`
+	LogitBias        map[int]float64 `json:"logit_bias,omitempty"` // token ID to bias, -100 to 100
+
+	// Optional settings applied when the request loads the model
+	Threads     *int  `json:"threads,omitempty"`
+	ContextSize *int  `json:"ctx_size,omitempty"`
+	BatchSize   *int  `json:"batch_size,omitempty"`
+	MLock       *bool `json:"mlock,omitempty"`
+	NoMMap      *bool `json:"no_mmap,omitempty"`
 }
 
-// ChatResponse is a single chunk of generated text
+// ChatResponse is a single chunk of generated text. The first chunk carries
+// only the request ID, for DELETE /api/v1/requests/:id. While the request
+// waits for the model, chunks carry only its place in the queue. The last
+// chunk has Done set and says why generation stopped, or carries Error.
 type ChatResponse struct {
-	Content string `json:"content"`
-	Done    bool   `json:"done"`
+	RequestID     string `json:"request_id,omitempty"`
+	Content       string `json:"content"`
+	Done          bool   `json:"done"`
+	QueuePosition int    `json:"queue_position,omitempty"`
+
+	Error           string   `json:"error,omitempty"`       // generation failed part way
+	StopReason      string   `json:"stop_reason,omitempty"` // eos, max_tokens, stop_word or cancelled
+	StopWord        string   `json:"stop_word,omitempty"`
+	TokensEvaluated int      `json:"tokens_evaluated,omitempty"` // prompt tokens processed
+	TokensPredicted int      `json:"tokens_predicted,omitempty"` // tokens generated
+	Timings         *Timings `json:"timings,omitempty"`
+}
+
+// Timings reports how fast the engine processed the prompt and generated
+type Timings struct {
+	PromptMs           float64 `json:"prompt_ms"`
+	PromptPerSecond    float64 `json:"prompt_per_second"`
+	PredictedMs        float64 `json:"predicted_ms"`
+	PredictedPerSecond float64 `json:"predicted_per_second"`
 }
 
 // ModelDownloadRequest triggers a new download
 type ModelDownloadRequest struct {
-	Url  string `json:"url"`
-	Name string `json:"name"`
+	Url    string `json:"url"`              // direct .gguf URL or Hugging Face reference (hf://org/repo[:file], org/repo:quant)
+	Name   string `json:"name"`             // file name in the models directory; defaults to the one in Url
+	SHA256 string `json:"sha256,omitempty"` // expected digest of the file, checked once it is complete
+}
+
+// TokenizeRequest asks for the token IDs of a text
+type TokenizeRequest struct {
+	Model   string `json:"model"`
+	Content string `json:"content"`
+}
+
+// TokenizeResponse holds the token IDs of the requested text
+type TokenizeResponse struct {
+	Tokens []int `json:"tokens"`
+}
+
+// EmbedRequest asks for the embedding vector of a text
+type EmbedRequest struct {
+	Model   string `json:"model"`
+	Content string `json:"content"`
+}
+
+// EmbedResponse holds the embedding vector of the requested text
+type EmbedResponse struct {
+	Embedding []float32 `json:"embedding"`
 }
 
 // ErrorResponse is a standard error wrapper
 type ErrorResponse struct {
 	Error string `json:"error"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,211 @@
+// Package integration runs the HTTP server end to end against the fake
+// engine started as a real child process.
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine"
+	"github.com/mibrahimzia/bitnet-runner/internal/engine/enginetest"
+	"github.com/mibrahimzia/bitnet-runner/internal/models/modeltest"
+	"github.com/mibrahimzia/bitnet-runner/internal/server"
+	"github.com/mibrahimzia/bitnet-runner/pkg/api"
+)
+
+func TestMain(m *testing.M) {
+	enginetest.RunIfEngine()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// start serves the API on a loopback port, running models with the fake engine
+func start(t *testing.T, script enginetest.Script) string {
+	t.Helper()
+	dir := modeltest.Home(t)
+	modeltest.WriteGGUF(t, filepath.Join(dir, "tiny.gguf"), map[string]any{"general.architecture": "llama"})
+
+	srv, err := server.NewServer(server.Config{
+		Pool:   engine.PoolConfig{MaxModels: 1},
+		Engine: enginetest.Process(t, script),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func post(t *testing.T, url string, body any) *http.Response {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func getJSON(t *testing.T, url string, v any) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	base := start(t, enginetest.Script{Tokens: []string{"Hi", " there"}, TokenDelay: 10 * time.Millisecond})
+
+	resp := post(t, base+"/v1/chat/completions", api.OpenAIChatRequest{
+		Model:    "tiny.gguf",
+		Messages: []api.OpenAIMessage{{Role: "user", Content: "hello"}},
+		Stream:   true,
+	})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %s, content type %q", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	var content strings.Builder
+	done := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk api.OpenAIChatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatal(err)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+	}
+	if !done || content.String() != "Hi there" {
+		t.Errorf("streamed %q (done %v)", content.String(), done)
+	}
+
+	var status server.EngineStatus
+	getJSON(t, base+"/api/v1/engine/status", &status)
+	if status.Engine.Source != engine.SourceFlag || status.Engine.Version != enginetest.Version {
+		t.Errorf("engine = %+v", status.Engine)
+	}
+	if len(status.Models) != 1 || status.Models[0].Port == "" {
+		t.Errorf("models = %+v", status.Models)
+	}
+}
+
+func TestLoadFailure(t *testing.T) {
+	base := start(t, enginetest.Script{ExitOnStart: true})
+
+	resp := post(t, base+"/api/generate", api.OllamaGenerateRequest{Model: "tiny.gguf", Prompt: "hello"})
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusBadGateway || !strings.Contains(string(body), "failed to load model") {
+		t.Errorf("status %s: %s", resp.Status, body)
+	}
+}
+
+func TestCrashIsReported(t *testing.T) {
+	base := start(t, enginetest.Script{Tokens: []string{"a", "b"}, CrashAfter: 1})
+
+	events, err := http.Get(base + "/api/v1/engine/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+
+	// The client gets the text sent before the crash, then an error
+	resp := post(t, base+"/api/generate", api.OllamaGenerateRequest{Model: "tiny.gguf", Prompt: "x"})
+	var lines []api.OllamaGenerateResponse
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var line api.OllamaGenerateResponse
+		if dec.Decode(&line) != nil {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 || lines[0].Response != "a" || lines[1].Done || lines[1].Error == "" {
+		t.Errorf("lines = %+v, want a token and an error", lines)
+	}
+
+	// The supervisor reports the crash and brings the engine back
+	var seen []string
+	scanner := bufio.NewScanner(events.Body)
+	for scanner.Scan() && !strings.Contains(strings.Join(seen, " "), "restarting ready") {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var ev engine.Event
+		json.Unmarshal([]byte(data), &ev)
+		seen = append(seen, string(ev.Type))
+	}
+	if got := strings.Join(seen, " "); !strings.Contains(got, "crashed restarting ready") {
+		t.Errorf("events = %q", got)
+	}
+
+	var crashes []engine.Crash
+	getJSON(t, base+"/api/v1/engine/crashes", &crashes)
+	if len(crashes) != 1 || !strings.Contains(strings.Join(crashes[0].Tail, "\n"), "crashing as scripted") {
+		t.Errorf("crashes = %+v", crashes)
+	}
+}
+
+func TestCancelByID(t *testing.T) {
+	base := start(t, enginetest.Script{
+		Tokens:     strings.Split(strings.Repeat("x", 200), ""),
+		TokenDelay: 10 * time.Millisecond,
+	})
+
+	resp := post(t, base+"/api/generate", api.OllamaGenerateRequest{Model: "tiny.gguf", Prompt: "x"})
+	id := resp.Header.Get("X-Request-Id")
+	if id == "" {
+		t.Fatal("no X-Request-Id header")
+	}
+
+	var active []engine.ActiveRequest
+	getJSON(t, base+"/api/v1/requests", &active)
+	if len(active) != 1 || active[0].ID != id {
+		t.Fatalf("requests = %+v, want %s", active, id)
+	}
+
+	req, _ := http.NewRequest("DELETE", base+"/api/v1/requests/"+id, nil)
+	cancelled, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled.Body.Close()
+	if cancelled.StatusCode != http.StatusNoContent {
+		t.Errorf("cancel: %s", cancelled.Status)
+	}
+
+	// The stream ends early with a final line
+	lines := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines++
+	}
+	if lines >= 200 {
+		t.Errorf("got %d lines, generation was not cancelled", lines)
+	}
+}
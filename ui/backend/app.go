@@ -3,10 +3,12 @@ package backend
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"github.com/mibrahimzia/bitnet-runner/internal/config"
 	"github.com/mibrahimzia/bitnet-runner/internal/engine"
-	"github.com/mibrahimzia/bitnet-runner/internal/embedder"
 	"github.com/mibrahimzia/bitnet-runner/internal/models"
 )
 
@@ -15,13 +17,19 @@ type App struct {
 	ctx          context.Context
 	modelManager *models.Manager
 	executor     *engine.Executor
-	cancelFunc   context.CancelFunc
+
+	mu     sync.Mutex
+	chatID string // request ID of the chat this window started last
+	preset string // generation preset of new chats; empty uses the model's
+
+	downloads map[string]context.CancelFunc // running downloads by file name
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	return &App{
 		modelManager: models.NewManager(),
+		downloads:    make(map[string]context.CancelFunc),
 	}
 }
 
@@ -31,15 +39,24 @@ func (a *App) Startup(ctx context.Context) {
 	fmt.Println("App starting up...")
 
 	
-	// 1. Extract Engine on startup
-	binPath, err := embedder.ExtractEngine()
+	// 1. Pick the engine ($BITNET_ENGINE, the config file, or the embedded one)
+	bin, err := engine.ResolveBinary("")
 	if err != nil {
 		runtime.EventsEmit(ctx, "error", "Failed to load engine: "+err.Error())
 		return
 	}
+	fmt.Printf("Using %s engine %s (version %s)\n", bin.Source, bin.Path, bin.Version)
 	
 	// 2. Initialize Executor with the path
-	a.executor = engine.NewExecutor(binPath)
+	a.executor = engine.NewExecutor(bin.Path)
+
+	// 3. Forward engine lifecycle events (crashes, restarts) to the UI
+	events, _ := a.executor.Subscribe()
+	go func() {
+		for ev := range events {
+			runtime.EventsEmit(ctx, "engine_event", ev)
+		}
+	}()
 }
 
 // shutdown is called at termination
@@ -57,22 +74,50 @@ func (a *App) ListModels() []models.ModelInfo {
 	return list
 }
 
-// DownloadModel triggers a download and emits events for progress
-func (a *App) DownloadModel(url string, name string) string {
+// DownloadModel triggers a download and emits events for progress. An
+// interrupted download of the same name resumes where it stopped; sha256,
+// if not empty, is checked once the file is complete.
+func (a *App) DownloadModel(url string, name string, sha256 string) string {
+	ctx, cancel := context.WithCancel(a.ctx)
+	ch, err := a.modelManager.Download(ctx, url, name, models.DownloadOptions{SHA256: sha256})
+	if err != nil {
+		cancel()
+		return "Error: " + err.Error()
+	}
+	a.mu.Lock()
+	a.downloads[name] = cancel
+	a.mu.Unlock()
+
 	go func() {
-		ch, err := a.modelManager.Download(url, name)
-		if err != nil {
-			runtime.EventsEmit(a.ctx, "download_error", err.Error())
-			return
-		}
-		
+		defer func() {
+			a.mu.Lock()
+			delete(a.downloads, name)
+			a.mu.Unlock()
+			cancel()
+		}()
 		for status := range ch {
+			if status.Error != "" {
+				runtime.EventsEmit(a.ctx, "download_error", status.Error)
+				continue
+			}
 			// Emit event to Frontend: "download_progress"
 			runtime.EventsEmit(a.ctx, "download_progress", status)
 		}
 	}()
 	return "Download started"
-} // Added missing closing brace
+}
+
+// CancelDownload stops a running download and removes its partial file
+func (a *App) CancelDownload(name string) string {
+	a.mu.Lock()
+	cancel, ok := a.downloads[name]
+	a.mu.Unlock()
+	if !ok {
+		return "Error: no download of " + name
+	}
+	cancel()
+	return "Download cancelled"
+}
 
 // LoadModelOnly starts the engine without chatting
 func (a *App) LoadModelOnly(modelFile string) string {
@@ -105,54 +150,109 @@ func (a *App) LoadModelOnly(modelFile string) string {
 // StartChat starts the inference and emits tokens via events
 // StartChat starts the inference
 func (a *App) StartChat(prompt string, modelFile string, temp float64, system string, topP float64, topK int, maxTokens int) string {
-	// 1. Resolve Model Path
-	list, _ := a.modelManager.List()
-	var fullPath string
-	for _, m := range list {
-		if m.ID == modelFile {
-			fullPath = m.FilePath
-			break
-		}
-	}
-	
-	if fullPath == "" {
+	return a.StartConversation(nil, prompt, modelFile, config.Params{
+		Temperature:  &temp,
+		TopP:         &topP,
+		TopK:         &topK,
+		MaxTokens:    &maxTokens,
+		SystemPrompt: system,
+	})
+}
+
+// StartConversation is StartChat with the earlier turns of the chat, so the
+// model sees the whole conversation instead of only the latest prompt.
+// params holds only the settings the user changed in the chat panel; the
+// others come from the model's defaults and the preset. It returns the ID
+// of the generation.
+func (a *App) StartConversation(history []engine.Message, prompt string, modelFile string, params config.Params) string {
+	// 1. Resolve the model; derived ones run on their base's file
+	model, err := a.modelManager.Find(modelFile)
+	if err != nil {
 		return "Error: Model not found"
 	}
 
-	// 2. Config
-	cfg := engine.InferenceConfig{
-		ModelPath:    fullPath,
-		Prompt:       prompt,
-		SystemPrompt: system,     // Use user value
-		Temperature:  temp,       // Use user value
-		TopP:         topP,       // Use user value
-		TopK:         topK,       // Use user value
-		MaxTokens:    maxTokens,  // Use user value
-		RepeatPenalty: 1.1,       // Fixed for now, or add argument
-		Threads:      4,
+	// 2. Config: the chat panel's values over the model's defaults and the preset
+	file, err := config.Load()
+	if err != nil {
+		return "Error: " + err.Error()
 	}
+	a.mu.Lock()
+	preset := a.preset
+	a.mu.Unlock()
+	cfg, err := engine.ResolveConfig(file, model, preset)
+	if err != nil {
+		return "Error: " + err.Error()
+	}
+	cfg.Messages = history
+	cfg.Prompt = prompt
+	cfg.Apply(params)
+
+	// Remember the request so Stop cancels this chat and nothing else
+	cfg.RequestID = engine.NewRequestID()
+	a.mu.Lock()
+	a.chatID = cfg.RequestID
+	a.mu.Unlock()
 
 	// 3. Run in background
 	go func() {
-		stream, err := a.executor.StartInference(cfg)
+		stream, err := a.executor.StartInference(a.ctx, cfg)
 		if err != nil {
 			runtime.EventsEmit(a.ctx, "chat_error", err.Error())
 			return
 		}
 
-		for token := range stream {
-			runtime.EventsEmit(a.ctx, "chat_token", token)
+		// chat_done carries the stop reason, token counts and timings
+		for ev := range stream {
+			switch ev.Type {
+			case engine.StreamToken:
+				runtime.EventsEmit(a.ctx, "chat_token", ev.Token)
+			case engine.StreamError:
+				runtime.EventsEmit(a.ctx, "chat_error", ev.Err.Error())
+			case engine.StreamDone:
+				runtime.EventsEmit(a.ctx, "chat_done", ev.Completion)
+			}
 		}
-		
-		runtime.EventsEmit(a.ctx, "chat_done", true)
 	}()
 
-	return "Inference started"
+	return cfg.RequestID
 }
 
-// StopChat kills the current process
+// ListPresets returns the names of the generation presets
+func (a *App) ListPresets() []string {
+	file, _ := config.Load()
+	var names []string
+	for name := range engine.Presets(file) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetPreset picks the preset new chats start from; an empty name goes back
+// to each model's own
+func (a *App) SetPreset(name string) string {
+	if name != "" {
+		file, err := config.Load()
+		if err != nil {
+			return "Error: " + err.Error()
+		}
+		if _, ok := engine.Presets(file)[name]; !ok {
+			return "Error: unknown preset " + name
+		}
+	}
+	a.mu.Lock()
+	a.preset = name
+	a.mu.Unlock()
+	return "Preset set"
+}
+
+// StopChat cancels the reply being generated; the model stays loaded
 func (a *App) StopChat() {
-	if a.executor != nil {
-		a.executor.Stop()
+	a.mu.Lock()
+	id := a.chatID
+	a.mu.Unlock()
+
+	if a.executor != nil && id != "" {
+		_ = a.executor.Cancel(id) // already finished is fine
 	}
 }
\ No newline at end of file
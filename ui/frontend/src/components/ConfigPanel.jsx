@@ -1,9 +1,27 @@
-import React, { useState } from 'react';
+import React, { useEffect, useState } from 'react';
 import { useChatStore } from '../stores/chatStore';
+import { ListPresets, SetPreset } from '../wailsjs/go/backend/App';
 
 export default function ConfigPanel() {
-  const { config, updateConfig } = useChatStore();
+  const { config, updateConfig, changed, resetConfig, preset, setPreset } = useChatStore();
   const [isOpen, setIsOpen] = useState(false);
+  const [presets, setPresets] = useState([]);
+
+  useEffect(() => {
+    ListPresets().then((list) => setPresets(list || []));
+  }, []);
+
+  const handlePreset = async (name) => {
+    const result = await SetPreset(name);
+    if (result.startsWith("Error")) {
+      alert(result);
+      return;
+    }
+    setPreset(name);
+  };
+
+  // Unchanged settings come from the preset and the model's defaults
+  const shown = (key) => (changed[key] ? config[key] : 'default');
 
   return (
     <div className="border-t border-gray-700">
@@ -17,12 +35,28 @@ export default function ConfigPanel() {
 
       {isOpen && (
         <div className="p-4 bg-surface space-y-4 text-sm animate-in slide-in-from-top-2">
+
+          {/* Preset */}
+          <div>
+            <label className="block text-xs text-gray-500 mb-1">Preset</label>
+            <select
+              className="w-full bg-background p-2 rounded border border-gray-600 text-xs focus:border-primary outline-none"
+              value={preset}
+              onChange={(e) => handlePreset(e.target.value)}
+            >
+              <option value="">Model default</option>
+              {presets.map((name) => (
+                <option key={name} value={name}>{name}</option>
+              ))}
+            </select>
+          </div>
           
           {/* System Prompt */}
           <div>
             <label className="block text-xs text-gray-500 mb-1">System Prompt</label>
             <textarea 
               className="w-full bg-background p-2 rounded border border-gray-600 text-xs h-20 focus:border-primary outline-none resize-none"
+              placeholder="Model default"
               value={config.systemPrompt}
               onChange={(e) => updateConfig('systemPrompt', e.target.value)}
             />
@@ -32,7 +66,7 @@ export default function ConfigPanel() {
           <div>
             <div className="flex justify-between mb-1">
               <label className="text-xs text-gray-500">Temperature</label>
-              <span className="text-xs text-primary">{config.temperature}</span>
+              <span className="text-xs text-primary">{shown('temperature')}</span>
             </div>
             <input 
               type="range" min="0" max="2" step="0.1"
@@ -46,7 +80,7 @@ export default function ConfigPanel() {
           <div>
             <div className="flex justify-between mb-1">
               <label className="text-xs text-gray-500">Max Tokens</label>
-              <span className="text-xs text-primary">{config.maxTokens}</span>
+              <span className="text-xs text-primary">{shown('maxTokens')}</span>
             </div>
             <input 
               type="range" min="128" max="4096" step="128"
@@ -60,7 +94,7 @@ export default function ConfigPanel() {
              <div>
             <div className="flex justify-between mb-1">
               <label className="text-xs text-gray-500">Repeat Penalty</label>
-              <span className="text-xs text-primary">{config.repeatPenalty}</span>
+              <span className="text-xs text-primary">{shown('repeatPenalty')}</span>
             </div>
             <input 
               type="range" min="1.0" max="2.0" step="0.05"
@@ -70,6 +104,13 @@ export default function ConfigPanel() {
             />
           </div>
 
+          <button
+            onClick={resetConfig}
+            className="text-xs text-gray-400 hover:text-white transition-colors"
+          >
+            Reset to defaults
+          </button>
+
         </div>
       )}
     </div>
@@ -1,17 +1,29 @@
 import React, { useState, useEffect } from 'react';
 import { useChatStore } from '../stores/chatStore';
-import { StartChat, StopChat } from '../wailsjs/go/backend/App';
+import { StartConversation, StopChat } from '../wailsjs/go/backend/App';
 import { EventsOn } from '../wailsjs/runtime/runtime';
 
+// Chat panel settings and their names in config.Params
+const paramNames = {
+  systemPrompt: 'system_prompt',
+  temperature: 'temperature',
+  topP: 'top_p',
+  topK: 'top_k',
+  maxTokens: 'max_tokens',
+  repeatPenalty: 'repeat_penalty',
+};
+
 export default function InputArea() {
   const [input, setInput] = useState('');
   const { 
+    messages,
     addMessage, 
     selectedModel, 
     isGenerating, 
     setGenerating, 
     updateLastMessage,
-    config // <--- We need this from the store
+    config, // <--- We need this from the store
+    changed
   } = useChatStore();
 
   // Setup Event Listeners
@@ -37,6 +49,11 @@ export default function InputArea() {
 
     const prompt = input;
     setInput('');
+
+    // Earlier turns are sent along so the model sees the whole conversation
+    const history = messages
+      .filter((m) => m.content)
+      .map((m) => ({ role: m.role, content: m.content }));
     
     // 1. Add User Message
     addMessage('user', prompt);
@@ -45,19 +62,17 @@ export default function InputArea() {
     addMessage('assistant', '');
     setGenerating(true);
 
-    // 3. Call Go Backend with ALL 7 ARGUMENTS
-    console.log("Sending config:", config); // Debug log
-    
+    // 3. Call Go Backend with the history and the settings the user changed;
+    // the others come from the model's defaults and the preset
+    const params = {};
+    for (const [key, name] of Object.entries(paramNames)) {
+      if (changed[key]) {
+        params[name] = key === 'systemPrompt' ? config[key] : Number(config[key]); // Ensure numbers are numbers
+      }
+    }
+
     try {
-      await StartChat(
-        prompt, 
-        selectedModel, 
-        Number(config.temperature), // Ensure numbers are numbers
-        config.systemPrompt,
-        Number(config.topP),
-        Number(config.topK),
-        Number(config.maxTokens)
-      );
+      await StartConversation(history, prompt, selectedModel, params);
     } catch (e) {
       console.error("Failed to start chat:", e);
       setGenerating(false);
@@ -7,6 +7,7 @@ export default function ModelSelector() {
   const { models, setModels, selectedModel, setSelectedModel } = useChatStore();
   const [loading, setLoading] = useState(false);
   const [loadedId, setLoadedId] = useState(null);
+  const [engineStatus, setEngineStatus] = useState(null);
 
   useEffect(() => {
     refreshModels();
@@ -20,6 +21,20 @@ export default function ModelSelector() {
         setLoading(false);
         alert("Load failed: " + err);
     });
+
+    // Crash / restart notifications from the engine supervisor
+    EventsOn("engine_event", (ev) => {
+        if (ev.type === "crashed") {
+            setEngineStatus("Engine crashed: " + ev.reason);
+        } else if (ev.type === "restarting") {
+            setEngineStatus(`Restarting engine (attempt ${ev.attempt})...`);
+        } else if (ev.type === "failed" && ev.attempt > 0) {
+            setEngineStatus("Engine could not be restarted");
+            setLoadedId(null);
+        } else if (ev.type === "ready") {
+            setEngineStatus(null);
+        }
+    });
   }, []);
 
   const refreshModels = async () => {
@@ -60,7 +75,7 @@ export default function ModelSelector() {
             {models.length === 0 && <option>No models found</option>}
             {models.map((m) => (
               <option key={m.id} value={m.id}>
-                {m.name}
+                {m.name}{m.quantization ? ` (${[m.architecture, m.quantization].filter(Boolean).join(' · ')})` : ''}
               </option>
             ))}
           </select>
@@ -80,6 +95,10 @@ export default function ModelSelector() {
                 loadedId === selectedModel ? "✔ Loaded" : "Load Model"
             )}
           </button>
+
+          {engineStatus && (
+            <p className="text-xs text-yellow-400 break-words">{engineStatus}</p>
+          )}
       </div>
     </div>
   );
@@ -8,7 +8,7 @@ export const useChatStore = create((set) => ({
   
   // --- NEW CONFIGURATION STATE ---
   config: {
-    systemPrompt: "You are a helpful, precise assistant. Do not repeat words or characters.",
+    systemPrompt: "",
     temperature: 0.7,
     topP: 0.9,
     topK: 40,
@@ -25,10 +25,20 @@ export const useChatStore = create((set) => ({
   setSelectedModel: (model) => set({ selectedModel: model }),
   setGenerating: (status) => set({ isGenerating: status }),
   
+  // Settings the user changed; only these override the model's defaults and the preset
+  changed: {},
+  preset: '',
+
   // New Config Actions
   updateConfig: (key, value) => set((state) => ({
-    config: { ...state.config, [key]: value }
+    config: { ...state.config, [key]: value },
+    changed: { ...state.changed, [key]: true }
+  })),
+  resetConfig: () => set((state) => ({
+    config: { ...state.config, systemPrompt: "" },
+    changed: {}
   })),
+  setPreset: (preset) => set({ preset }),
 
   addMessage: (role, content) => set((state) => ({
     messages: [...state.messages, { role, content, timestamp: new Date() }]
@@ -1,11 +1,21 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {models} from '../models';
+import {engine} from '../models';
+import {config} from '../models';
 
-export function DownloadModel(arg1:string,arg2:string):Promise<string>;
+export function CancelDownload(arg1:string):Promise<string>;
+
+export function DownloadModel(arg1:string,arg2:string,arg3:string):Promise<string>;
 
 export function ListModels():Promise<Array<models.ModelInfo>>;
 
+export function ListPresets():Promise<Array<string>>;
+
+export function SetPreset(arg1:string):Promise<string>;
+
 export function StartChat(arg1:string,arg2:string,arg3:number):Promise<string>;
 
+export function StartConversation(arg1:Array<engine.Message>,arg2:string,arg3:string,arg4:config.Params):Promise<string>;
+
 export function StopChat():Promise<void>;
@@ -2,18 +2,34 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWLIO
 // This file is automatically generated. DO NOT EDIT
 
-export function DownloadModel(arg1, arg2) {
-  return window['go']['backend']['App']['DownloadModel'](arg1, arg2);
+export function CancelDownload(arg1) {
+  return window['go']['backend']['App']['CancelDownload'](arg1);
+}
+
+export function DownloadModel(arg1, arg2, arg3) {
+  return window['go']['backend']['App']['DownloadModel'](arg1, arg2, arg3);
 }
 
 export function ListModels() {
   return window['go']['backend']['App']['ListModels']();
 }
 
+export function ListPresets() {
+  return window['go']['backend']['App']['ListPresets']();
+}
+
+export function SetPreset(arg1) {
+  return window['go']['backend']['App']['SetPreset'](arg1);
+}
+
 export function StartChat(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
   return window['go']['backend']['App']['StartChat'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
 }
 
+export function StartConversation(arg1, arg2, arg3, arg4) {
+  return window['go']['backend']['App']['StartConversation'](arg1, arg2, arg3, arg4);
+}
+
 export function StopChat() {
   return window['go']['backend']['App']['StopChat']();
 }
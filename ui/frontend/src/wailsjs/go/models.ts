@@ -1,3 +1,81 @@
+export namespace config {
+	
+	export class Params {
+	    temperature?: number;
+	    top_p?: number;
+	    top_k?: number;
+	    min_p?: number;
+	    typical_p?: number;
+	    repeat_penalty?: number;
+	    presence_penalty?: number;
+	    frequency_penalty?: number;
+	    mirostat?: number;
+	    mirostat_tau?: number;
+	    mirostat_eta?: number;
+	    logit_bias?: {[key: number]: number};
+	    max_tokens?: number;
+	    seed?: number;
+	    stop?: string[];
+	    system_prompt?: string;
+	    template?: string;
+	    threads?: number;
+	    ctx_size?: number;
+	    batch_size?: number;
+	    mlock?: boolean;
+	    no_mmap?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Params(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.temperature = source["temperature"];
+	        this.top_p = source["top_p"];
+	        this.top_k = source["top_k"];
+	        this.min_p = source["min_p"];
+	        this.typical_p = source["typical_p"];
+	        this.repeat_penalty = source["repeat_penalty"];
+	        this.presence_penalty = source["presence_penalty"];
+	        this.frequency_penalty = source["frequency_penalty"];
+	        this.mirostat = source["mirostat"];
+	        this.mirostat_tau = source["mirostat_tau"];
+	        this.mirostat_eta = source["mirostat_eta"];
+	        this.logit_bias = source["logit_bias"];
+	        this.max_tokens = source["max_tokens"];
+	        this.seed = source["seed"];
+	        this.stop = source["stop"];
+	        this.system_prompt = source["system_prompt"];
+	        this.template = source["template"];
+	        this.threads = source["threads"];
+	        this.ctx_size = source["ctx_size"];
+	        this.batch_size = source["batch_size"];
+	        this.mlock = source["mlock"];
+	        this.no_mmap = source["no_mmap"];
+	    }
+	}
+
+}
+
+export namespace engine {
+	
+	export class Message {
+	    role: string;
+	    content: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Message(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.role = source["role"];
+	        this.content = source["content"];
+	    }
+	}
+
+}
+
 export namespace models {
 	
 	export class ModelInfo {
@@ -9,6 +87,11 @@ export namespace models {
 	    // Go type: time
 	    modified: any;
 	    is_download: boolean;
+	    architecture: string;
+	    parameter_count: number;
+	    quantization: string;
+	    context_length: number;
+	    vocab_size: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new ModelInfo(source);
@@ -23,6 +106,11 @@ export namespace models {
 	        this.size = source["size"];
 	        this.modified = this.convertValues(source["modified"], null);
 	        this.is_download = source["is_download"];
+	        this.architecture = source["architecture"];
+	        this.parameter_count = source["parameter_count"];
+	        this.quantization = source["quantization"];
+	        this.context_length = source["context_length"];
+	        this.vocab_size = source["vocab_size"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {